@@ -0,0 +1,89 @@
+package model
+
+// Collection models in the order of query, like the result of Collect and
+// the value of has many nexus
+type Collection []interface{}
+
+// KeyBy map models by the value of col, a later model wins for the same value
+func (c Collection) KeyBy(col string) map[interface{}]interface{} {
+	result := make(map[interface{}]interface{})
+	for _, m := range c {
+		result[m.(Model).Get(col)] = m
+	}
+	return result
+}
+
+// Get find the model whose pk value is id, an int id finds an int64 pk
+func (c Collection) Get(id interface{}) (interface{}, bool) {
+	key := nexusKey(id)
+	for _, m := range c {
+		if nexusKey(m.(Model).Get(m.(Model).PK())) == key {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// GroupBy group models by the value of col, models of a group are in order
+func (c Collection) GroupBy(col string) map[interface{}]Collection {
+	result := make(map[interface{}]Collection)
+	for _, m := range c {
+		key := m.(Model).Get(col)
+		result[key] = append(result[key], m)
+	}
+	return result
+}
+
+// Pluck list the value of col of each model
+func (c Collection) Pluck(col string) []interface{} {
+	result := make([]interface{}, len(c))
+	for i, m := range c {
+		result[i] = m.(Model).Get(col)
+	}
+	return result
+}
+
+// Filter find the models accepted by accept
+func (c Collection) Filter(accept func(m interface{}) bool) Collection {
+	result := Collection{}
+	for _, m := range c {
+		if accept(m) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Load eager load nexus names of all models, one query a nexus like With.
+// names can be dotted like "books.author"
+func (c Collection) Load(names ...string) error {
+	if len(c) == 0 || len(names) == 0 {
+		return nil
+	}
+	r := c[0].(Model).Repo().Another()
+	for _, name := range names {
+		r.With(name)
+	}
+	return r.loadNexus(c)
+}
+
+func (c Collection) MustLoad(names ...string) Collection {
+	if err := c.Load(names...); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (repo *Repo) MustCollect() Collection {
+	if c, err := repo.Collect(); err != nil {
+		panic(err)
+	} else {
+		return c
+	}
+}
+
+// Collect fetch models as a Collection in the order of query
+func (repo *Repo) Collect() (Collection, error) {
+	models, err := repo.Fetch()
+	return Collection(models), err
+}
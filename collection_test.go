@@ -0,0 +1,30 @@
+package model
+
+import (
+	. "testing"
+)
+
+func TestCollection(t *T) {
+	c := Collection{}
+	for i, age := range []int{17, 18, 17} {
+		c = append(c, New(&FilterUser{Id: string(rune('a' + i)), Age: age}))
+	}
+	if m, ok := c.Get("b"); !ok || m.(*FilterUser).Age != 18 {
+		t.Fatal("collection get error")
+	}
+	if groups := c.GroupBy("age"); len(groups) != 2 || len(groups[17]) != 2 || groups[17][1] != c[2] {
+		t.Fatal("collection group by error")
+	}
+	if ids := c.Pluck("id"); len(ids) != 3 || ids[2] != "c" {
+		t.Fatal("collection pluck error")
+	}
+	adults := c.Filter(func(m interface{}) bool {
+		return m.(*FilterUser).Age >= 18
+	})
+	if len(adults) != 1 || adults[0] != c[1] {
+		t.Fatal("collection filter error")
+	}
+	if len(c.KeyBy("age")) != 2 {
+		t.Fatal("collection key by error")
+	}
+}
@@ -122,6 +122,36 @@ func (db *Db) Tx(handle txhandler) error {
 	return nil
 }
 
+// transaction run handle in the current tx if there is one, otherwise in a new tx
+func (db *Db) transaction(handle txhandler) error {
+	if tx := db.tx(); tx != nil {
+		return handle(tx)
+	}
+	return db.Tx(handle)
+}
+
+// savepoint run handle in a savepoint of the current tx if there is one, and
+// roll back to the savepoint if handle fails, so the tx is still usable after
+// an error like a unique violation. otherwise run handle in a new tx
+func (db *Db) savepoint(name string, handle txhandler) error {
+	tx := db.tx()
+	if tx == nil {
+		return db.Tx(handle)
+	}
+	if _, err := tx.Exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+	if err := handle(tx); err != nil {
+		if _, e := tx.Exec("ROLLBACK TO SAVEPOINT " + name); e != nil {
+			return e
+		}
+		return err
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT " + name)
+
+	return err
+}
+
 func (db *Db) Begin() error {
 	tx, err := db.DB.Begin()
 	db.txs = append(db.txs, tx)
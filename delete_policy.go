@@ -0,0 +1,171 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	. "github.com/yang-zzhong/go-querybuilder"
+)
+
+// what to do with the targets of a relationship when model is deleted
+type DeletePolicy int
+
+const (
+	ON_DELETE_NONE     DeletePolicy = iota // do nothing
+	ON_DELETE_CASCADE                      // delete the targets one by one, their delete hooks fired
+	ON_DELETE_SET_NULL                     // set the nexus cols of the targets to null, never undone by Restore
+	ON_DELETE_RESTRICT                     // refuse to delete model if it has targets
+)
+
+// find the delete policies declared by model
+type deletePolicied interface {
+	deletePolicies() map[string]DeletePolicy
+}
+
+// DeclareOnDelete declare the delete policy of relationship name, enforced
+// in a tx by Delete, Deletes, ForceDelete and ForceDeletes of the repo. for
+// a belongs to many relationship, cascade and set null detach the pivot rows.
+// a has one nexus referred by model, like the author of a book, takes only
+// restrict, cascade or set null on it is rejected when enforced.
+// targets soft deleted already are left alone unless model is force deleted,
+// then they are force deleted too. set null on a soft delete is kept after
+// model restored, the nexus cols of targets are not set back
+//
+//	user.DeclareMany("books", new(Book), Nexus{"user_id": "id"})
+//	user.DeclareOnDelete("books", ON_DELETE_CASCADE)
+func (base *Base) DeclareOnDelete(name string, policy DeletePolicy) {
+	base.policies[name] = policy
+}
+
+func (base *Base) deletePolicies() map[string]DeletePolicy {
+	return base.policies
+}
+
+// cascade enforce the delete policies of models in a tx of the repo db, then
+// delete models by del. force tell if models are force deleted
+func (repo *Repo) cascade(models []interface{}, force bool, del func() error) error {
+	var policies map[string]DeletePolicy
+	if p, ok := repo.model.(deletePolicied); ok {
+		policies = p.deletePolicies()
+	}
+	if len(policies) == 0 || len(models) == 0 {
+		return del()
+	}
+	return repo.model.(Model).DB().transaction(func(_ *sql.Tx) error {
+		for name, policy := range policies {
+			if err := repo.enforce(name, policy, models, force); err != nil {
+				return err
+			}
+		}
+		return del()
+	})
+}
+
+// enforce the delete policy of relationship name on the targets of models
+func (repo *Repo) enforce(name string, policy DeletePolicy, models []interface{}, force bool) error {
+	if policy == ON_DELETE_NONE {
+		return nil
+	}
+	rel := repo.relation(name)
+	switch rel.t {
+	case t_pivot:
+		return repo.enforcePivot(name, rel.pivot, policy, models)
+	case t_one, t_many:
+	default:
+		return &Error{ERR_NEXUS_UNDEFINED, errors.New("has one, has many or belongs to many nexus " + name + " not exists")}
+	}
+	if referred(rel) && (policy == ON_DELETE_CASCADE || policy == ON_DELETE_SET_NULL) {
+		// the target is referred by model, like the author of a book, it's
+		// never deleted or changed for model deleted
+		return &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + name + " refers model's parent, only restrict applies")}
+	}
+	nm, err := repo.nexusModel(with{name: name, m: rel.target, n: rel.n, t: rel.t}, models)
+	if err != nil {
+		return err
+	}
+	r := nm.(Model).Repo()
+	if force {
+		// rows referring models can't be kept, soft deleted or not
+		r.WithTrashed()
+	}
+	switch policy {
+	case ON_DELETE_RESTRICT:
+		count, err := r.Count()
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			return &Error{ERR_DELETE_RESTRICTED, errors.New("model has " + name + ", delete restricted")}
+		}
+	case ON_DELETE_CASCADE:
+		targets, err := r.Fetch()
+		if err != nil {
+			return err
+		}
+		for _, target := range targets {
+			if force {
+				err = target.(deletable).ForceDelete()
+			} else {
+				err = target.(deletable).Delete()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case ON_DELETE_SET_NULL:
+		values := make(map[string]interface{})
+		for af, bf := range rel.n {
+			if _, ok := bf.(string); ok {
+				values[af] = nil
+			}
+		}
+		// targets soft deleted already are left alone unless forced
+		if err = r.prepare(); err != nil {
+			return err
+		}
+		return r.UpdateRaw(values)
+	}
+
+	return nil
+}
+
+// enforcePivot enforce the delete policy on the pivot rows of models
+func (repo *Repo) enforcePivot(name string, p *pivot, policy DeletePolicy, models []interface{}) error {
+	b := NewBuilder(repo.modifier)
+	b.From(p.table)
+	for pc, mc := range p.local {
+		switch v := mc.(type) {
+		case NWhere:
+			b.Where(pc, v.Op, v.Value)
+		case string:
+			vals := []interface{}{}
+			for _, m := range models {
+				val, err := repo.model.(Mapable).Mapper().colValue(m, v)
+				if err != nil {
+					return err
+				}
+				vals = append(vals, val)
+			}
+			b.WhereIn(pc, vals)
+		}
+	}
+	db := repo.model.(Model).DB()
+	if policy != ON_DELETE_RESTRICT {
+		_, err := db.Exec(b.ForRemove(), b.Params()...)
+		return err
+	}
+	var count int
+	if err := db.QueryRow(b.ForCount(), b.Params()...).Scan(&count); err != nil {
+		return err
+	}
+	if count != 0 {
+		return &Error{ERR_DELETE_RESTRICTED, errors.New("model has " + name + ", delete restricted")}
+	}
+
+	return nil
+}
+
+// delete a model with hooks fired
+type deletable interface {
+	Delete() error
+	ForceDelete() error
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	. "github.com/yang-zzhong/go-querybuilder"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dialect_mysql  = 1
+	dialect_pgsql  = 2
+	dialect_sqlite = 3
+)
+
+// dialectOf tell the sql dialect of a modifier, a modifier other than mysql
+// and pgsql is taken as sqlite
+func dialectOf(m Modifier) int {
+	switch m.(type) {
+	case *MysqlModifier, MysqlModifier:
+		return dialect_mysql
+	case *PgsqlModifier, PgsqlModifier:
+		return dialect_pgsql
+	}
+	return dialect_sqlite
+}
+
+// concat generate the sql concatenating exprs as a string in dialect
+func concat(dialect int, exprs ...string) string {
+	if dialect == dialect_mysql {
+		return "CONCAT(" + strings.Join(exprs, ", ") + ")"
+	}
+	return "(" + strings.Join(exprs, " || ") + ")"
+}
+
+// quote quote the identifier name in dialect
+func quote(dialect int, name string) string {
+	if dialect == dialect_mysql {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// text generate the sql casting expr to a string in dialect
+func text(dialect int, expr string) string {
+	if dialect == dialect_mysql {
+		return "CAST(" + expr + " AS CHAR(4096))"
+	}
+	return "CAST(" + expr + " AS TEXT)"
+}
+
+// bind number the ? placeholders in sqlang from offset + 1 for pgsql,
+// other dialects use ? as it is
+func bind(dialect int, sqlang string, offset int) string {
+	if dialect != dialect_pgsql {
+		return sqlang
+	}
+	var result strings.Builder
+	for _, c := range sqlang {
+		if c == '?' {
+			offset++
+			result.WriteString("$" + strconv.Itoa(offset))
+			continue
+		}
+		result.WriteRune(c)
+	}
+	return result.String()
+}
+
+// bindArgs put params of a bound fragment and params of the sql it's bound
+// into together in the placeholder order of dialect
+func bindArgs(dialect int, fragment []interface{}, sql []interface{}) []interface{} {
+	if dialect == dialect_pgsql {
+		return append(append([]interface{}{}, sql...), fragment...)
+	}
+	return append(append([]interface{}{}, fragment...), sql...)
+}
+
+// unbind turn the $n placeholders of pgsql in sqlang back to ?
+func unbind(dialect int, sqlang string) string {
+	if dialect != dialect_pgsql {
+		return sqlang
+	}
+	var result strings.Builder
+	for i := 0; i < len(sqlang); i++ {
+		if sqlang[i] != '$' || i+1 == len(sqlang) || sqlang[i+1] < '0' || sqlang[i+1] > '9' {
+			result.WriteByte(sqlang[i])
+			continue
+		}
+		for i+1 < len(sqlang) && sqlang[i+1] >= '0' && sqlang[i+1] <= '9' {
+			i++
+		}
+		result.WriteByte('?')
+	}
+	return result.String()
+}
+
+// interpolate replace the placeholders in sqlang with params as literals,
+// for debug only, never run the result
+func interpolate(dialect int, sqlang string, params []interface{}) string {
+	var result strings.Builder
+	quoted := false
+	next := 0
+	for i := 0; i < len(sqlang); i++ {
+		c := sqlang[i]
+		if c == '\'' {
+			quoted = !quoted
+		}
+		if quoted || (dialect == dialect_pgsql && c != '$') || (dialect != dialect_pgsql && c != '?') {
+			result.WriteByte(c)
+			continue
+		}
+		index := next
+		if dialect == dialect_pgsql {
+			j := i + 1
+			for j < len(sqlang) && sqlang[j] >= '0' && sqlang[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(sqlang[i+1 : j])
+			if err != nil {
+				result.WriteByte(c)
+				continue
+			}
+			index = n - 1
+			i = j - 1
+		}
+		next++
+		if index < 0 || index >= len(params) {
+			result.WriteString("?")
+			continue
+		}
+		result.WriteString(literal(params[index]))
+	}
+	return result.String()
+}
+
+// literal format a param as a sql literal
+func literal(param interface{}) string {
+	if valuer, ok := param.(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil {
+			return literal(v)
+		}
+	}
+	switch v := param.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case []byte:
+		return literal(string(v))
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05") + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprint(param)
+}
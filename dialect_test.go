@@ -0,0 +1,47 @@
+package model
+
+import (
+	. "testing"
+)
+
+func TestBind(t *T) {
+	if sqlang := bind(dialect_pgsql, "a = a + ?, b = ?", 2); sqlang != "a = a + $3, b = $4" {
+		t.Fatalf("bind pgsql error: %v", sqlang)
+	}
+	if sqlang := bind(dialect_mysql, "a = a + ?, b = ?", 2); sqlang != "a = a + ?, b = ?" {
+		t.Fatalf("bind mysql error: %v", sqlang)
+	}
+	if sqlang := unbind(dialect_pgsql, "a = $1 AND b IN ($2, $13)"); sqlang != "a = ? AND b IN (?, ?)" {
+		t.Fatalf("unbind pgsql error: %v", sqlang)
+	}
+	if sqlang := concat(dialect_mysql, "a", "','"); sqlang != "CONCAT(a, ',')" {
+		t.Fatal("mysql concat error")
+	}
+	if sqlang := text(dialect_pgsql, concat(dialect_pgsql, "a", "','")); sqlang != "CAST((a || ',') AS TEXT)" {
+		t.Fatal("pgsql concat error")
+	}
+	if name := quote(dialect_mysql, "order"); name != "`order`" {
+		t.Fatalf("quote mysql error: %v", name)
+	}
+	if name := quote(dialect_pgsql, `a"b`); name != `"a""b"` {
+		t.Fatalf("quote pgsql error: %v", name)
+	}
+	args := bindArgs(dialect_pgsql, []interface{}{3, 4}, []interface{}{1, 2})
+	if len(args) != 4 || args[0] != 1 || args[2] != 3 {
+		t.Fatal("bind args pgsql error")
+	}
+	args = bindArgs(dialect_mysql, []interface{}{3, 4}, []interface{}{1, 2})
+	if len(args) != 4 || args[0] != 3 || args[2] != 1 {
+		t.Fatal("bind args mysql error")
+	}
+}
+
+func TestInterpolate(t *T) {
+	params := []interface{}{"it's", 18, nil}
+	if sqlang := interpolate(dialect_mysql, "name = ? AND age > ? AND code = '?' AND level = ?", params); sqlang != "name = 'it''s' AND age > 18 AND code = '?' AND level = NULL" {
+		t.Fatalf("interpolate mysql error: %v", sqlang)
+	}
+	if sqlang := interpolate(dialect_pgsql, "age > $2 AND name = $1", params); sqlang != "age > 18 AND name = 'it''s'" {
+		t.Fatalf("interpolate pgsql error: %v", sqlang)
+	}
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	helpers "github.com/yang-zzhong/go-helpers"
+	"strings"
 )
 
 const (
@@ -12,6 +13,9 @@ const (
 	ERR_DATA_NOT_FOUND
 	ERR_COL_UNDEFINED
 	ERR_UNKNOWN_COLTYPE
+	ERR_BAD_FILTER
+	ERR_NOT_IN_TX
+	ERR_DELETE_RESTRICTED
 )
 
 type Error struct {
@@ -26,3 +30,32 @@ func (err *Error) Error() string {
 func IsModelErr(err error) bool {
 	return helpers.InstanceOf(err, &Error{})
 }
+
+// IsUniqueViolation tell if err is caused by a unique index of mysql, pgsql or sqlite
+func IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range []string{
+		"Duplicate entry",          // mysql
+		"duplicate key value",      // pgsql
+		"UNIQUE constraint failed", // sqlite
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors collect the errors of several models, like errors of Push
+type Errors []error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
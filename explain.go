@@ -0,0 +1,165 @@
+package model
+
+import (
+	"database/sql"
+	. "github.com/yang-zzhong/go-querybuilder"
+)
+
+// a query generated by repo
+type Statement struct {
+	Nexus   string // nexus path or aggregate extra name of an eager load query, empty for the main query
+	SQL     string
+	Params  []interface{}
+	dialect int
+}
+
+// a query plan of a statement
+type Plan struct {
+	Statement
+	Rows []map[string]interface{} // rows returned by EXPLAIN
+}
+
+// String interpolate params into the sql for debug, never run it
+func (stmt Statement) String() string {
+	return interpolate(stmt.dialect, stmt.SQL, stmt.Params)
+}
+
+// ToSQL generate the main query of repo as it will be sent to db
+func (repo *Repo) ToSQL() (Statement, error) {
+	if err := repo.prepare(); err != nil {
+		return Statement{}, err
+	}
+	return Statement{"", repo.forQuery(), repo.params(), dialectOf(repo.modifier)}, nil
+}
+
+// FetchStatements generate the main query and every eager load query of
+// repo: the pivot row query and the target query of each With, the queries
+// of nested levels like With("books.author") named "books.author", and the
+// query of each aggregate like WithCount named by its extra name. like a
+// fetch, it runs the main query of each level, with its row lock and
+// AFTER_FETCH hooks, and the pivot row queries, to find the where in values
+// of the next level. a nexus of WithCustom is reported by the query of its
+// target repo before the handler, the handler may change it or run others
+func (repo *Repo) FetchStatements() (stmts []Statement, err error) {
+	var stmt Statement
+	if stmt, err = repo.ToSQL(); err != nil {
+		return
+	}
+	stmts = append(stmts, stmt)
+	if len(repo.withs) == 0 && len(repo.aggs) == 0 {
+		return
+	}
+	models := []interface{}{}
+	err = repo.fetch(func(m interface{}, _ interface{}) error {
+		models = append(models, m)
+		return nil
+	})
+	if err != nil || len(models) == 0 {
+		return
+	}
+	dialect := dialectOf(repo.modifier)
+	for _, w := range repo.withs {
+		nms := []interface{}{}
+		if w.t == t_morph {
+			var morphs map[string]interface{}
+			if morphs, err = repo.morphModels(w, models); err != nil {
+				return
+			}
+			// one query a morph type
+			for _, alias := range morphAliasesOf(morphs) {
+				nms = append(nms, morphs[alias])
+			}
+		} else {
+			if w.t == t_pivot {
+				var b *Builder
+				if b, err = repo.pivotQuery(w, models); err != nil {
+					return
+				}
+				stmts = append(stmts, Statement{w.name, b.ForQuery(), b.Params(), dialect})
+			}
+			var nm interface{}
+			if nm, err = repo.nexusModel(w, models); err != nil {
+				return
+			}
+			nms = append(nms, nm)
+		}
+		for _, nm := range nms {
+			var nested []Statement
+			if nested, err = nm.(Model).Repo().FetchStatements(); err != nil {
+				return
+			}
+			for i, stmt := range nested {
+				if i == 0 {
+					stmt.Nexus = w.name
+				} else {
+					stmt.Nexus = w.name + "." + stmt.Nexus
+				}
+				stmts = append(stmts, stmt)
+			}
+		}
+	}
+	for _, agg := range repo.aggs {
+		var r *Repo
+		if r, _, err = repo.aggregateRepo(agg, models); err != nil {
+			return
+		}
+		if stmt, err = r.ToSQL(); err != nil {
+			return
+		}
+		stmt.Nexus = agg.as
+		stmts = append(stmts, stmt)
+	}
+
+	return
+}
+
+// Explain run EXPLAIN of the main query and each eager load query generated
+// by FetchStatements, with the same side effects
+func (repo *Repo) Explain() (plans []Plan, err error) {
+	var stmts []Statement
+	if stmts, err = repo.FetchStatements(); err != nil {
+		return
+	}
+	for _, stmt := range stmts {
+		explain := "EXPLAIN "
+		if stmt.dialect == dialect_sqlite {
+			explain = "EXPLAIN QUERY PLAN "
+		}
+		plan := Plan{stmt, []map[string]interface{}{}}
+		err = repo.query(explain+stmt.SQL, stmt.Params, func(rows *sql.Rows, columns []string) error {
+			row, err := scanMap(rows, columns)
+			if err == nil {
+				plan.Rows = append(plan.Rows, row)
+			}
+			return err
+		})
+		if err != nil {
+			return
+		}
+		plans = append(plans, plan)
+	}
+
+	return
+}
+
+// scanMap scan current row to a map of col to value
+func scanMap(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i, _ := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, &Error{ERR_SCAN, err}
+	}
+	row := make(map[string]interface{})
+	for i, colname := range columns {
+		if b, ok := values[i].([]byte); ok {
+			row[colname] = string(b)
+		} else {
+			row[colname] = values[i]
+		}
+	}
+
+	return row, nil
+}
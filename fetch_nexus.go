@@ -1,8 +1,14 @@
 package model
 
 import (
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type NWhere struct {
@@ -15,33 +21,104 @@ type NexusValues interface {
 }
 
 type DefaultNexusValues struct {
-	data map[interface{}]interface{}
+	data  Collection
+	index map[string]map[string]Collection // data indexed by nexus cols and their values
 }
 
+// NewNexusValues make the default nexus values of data, the order of data is lost
 func NewNexusValues(data map[interface{}]interface{}) NexusValues {
-	return &DefaultNexusValues{data}
+	c := Collection{}
+	for _, m := range data {
+		c = append(c, m)
+	}
+	return &DefaultNexusValues{data: c}
+}
+
+// NewNexusCollection make the default nexus values of c, the order of c is kept
+func NewNexusCollection(c Collection) NexusValues {
+	return &DefaultNexusValues{data: c}
 }
 
 func (nve *DefaultNexusValues) DataOf(m interface{}, rel Nexus) interface{} {
-	result := make(map[interface{}]interface{})
-	for k, item := range nve.data {
-		eq := true
-		for field, val := range rel {
-			switch val.(type) {
-			case string:
-				a, _ := m.(Mapable).Mapper().colValue(m, val.(string))
-				b, _ := item.(Mapable).Mapper().colValue(item, field)
-				if a != b {
-					eq = false
-					break
-				}
-			}
+	cols := nexusCols(rel)
+	vals := make([]interface{}, len(cols))
+	for i, col := range cols {
+		vals[i], _ = m.(Mapable).Mapper().colValue(m, rel[col].(string))
+	}
+	return append(Collection{}, nve.indexOf(cols)[nexusKey(vals...)]...)
+}
+
+// indexOf index data by the values of cols once
+func (nve *DefaultNexusValues) indexOf(cols []string) map[string]Collection {
+	if nve.index == nil {
+		nve.index = make(map[string]map[string]Collection)
+	}
+	name := strings.Join(cols, ",")
+	if index, ok := nve.index[name]; ok {
+		return index
+	}
+	index := make(map[string]Collection)
+	for _, item := range nve.data {
+		vals := make([]interface{}, len(cols))
+		for i, col := range cols {
+			vals[i], _ = item.(Mapable).Mapper().colValue(item, col)
 		}
-		if eq {
-			result[k] = item
+		key := nexusKey(vals...)
+		index[key] = append(index[key], item)
+	}
+	nve.index[name] = index
+
+	return index
+}
+
+// nexusKey make a comparable key of nexus col values, values of the same
+// number or time in different types make the same key
+func nexusKey(values ...interface{}) string {
+	keys := make([]string, len(values))
+	for i, value := range values {
+		keys[i] = normalKey(value)
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// normalKey format value, numbers and times are formatted regardless of type
+func normalKey(value interface{}) string {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return fmt.Sprint(value)
 		}
+		value = v
+	}
+	switch v := value.(type) {
+	case nil:
+		return "\x01"
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
 	}
-	return result
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "\x01"
+		}
+		return normalKey(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f == math.Trunc(f) && math.Abs(f) < 1e18 {
+			return strconv.FormatInt(int64(f), 10)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return fmt.Sprint(value)
 }
 
 // a nexus result struct to hold the query result of a nexus
@@ -59,34 +136,83 @@ type fornexusHandler func(field, op string, value interface{})
 // With tell repo that find nexus defined by model
 // if nexus not defined, With will ignore
 func (repo *Repo) WithCustom(name string, handler repoHandler) *Repo {
-	t := t_bad
-	var ok bool
-	var m interface{}
-	var n map[string]interface{}
-	if m, n, ok = repo.model.(NexusOne).HasOne(name); ok {
-		t = t_one
-	} else if m, n, ok = repo.model.(NexusMany).HasMany(name); ok {
-		t = t_many
-	}
+	rel := repo.relation(name)
 	repo.withs = append(repo.withs, with{
 		name:    name,
-		m:       m,
-		n:       n,
-		t:       t,
+		m:       rel.target,
+		n:       rel.n,
+		t:       rel.t,
+		pivot:   rel.pivot,
+		morph:   rel.morph,
+		through: rel.through,
 		handler: handler,
 	})
 	return repo
 }
 
-func (repo *Repo) With(name string) *Repo {
-	return repo.WithCustom(name, func(m interface{}) (data NexusValues, err error) {
-		if d, e := m.(Model).Repo().FetchKey(m.(Model).PK()); e != nil {
-			err = e
-		} else {
-			data = &DefaultNexusValues{d}
+// relation find the relationship named name declared by model, typed t_bad if not declared
+func (repo *Repo) relation(name string) relationship {
+	if r, ok := repo.model.(relational); ok {
+		if rel, ok := r.relation(name); ok {
+			return rel
 		}
-		return
-	})
+	}
+	return relationship{t: t_bad}
+}
+
+// nexus find the target, nexus and type of the nexus named name declared by model
+func (repo *Repo) nexus(name string) (m interface{}, n Nexus, t int) {
+	rel := repo.relation(name)
+	return rel.target, rel.n, rel.t
+}
+
+// With tell repo that find nexus defined by model. a dotted name like
+// "books.publisher" finds the nexus of each level too, one query a level.
+// constraints modify the target repo of the last level before fetching, a
+// Limit in constraints limits the targets of all models, not of each model
+//
+//	repo.With("books", func(r *Repo) {
+//		r.Where("published", true)
+//		r.OrderBy("published_at", DESC)
+//	})
+func (repo *Repo) With(name string, constraints ...func(*Repo)) *Repo {
+	path := strings.SplitN(name, ".", 2)
+	w := repo.with(path[0])
+	if len(path) == 2 {
+		w.constraints = append(w.constraints, func(r *Repo) {
+			r.With(path[1], constraints...)
+		})
+	} else {
+		w.constraints = append(w.constraints, constraints...)
+	}
+	return repo
+}
+
+// with find the with of nexus name, add one if not exists
+func (repo *Repo) with(name string) *with {
+	for i, w := range repo.withs {
+		if w.name == name {
+			return &repo.withs[i]
+		}
+	}
+	repo.WithCustom(name, fetchNexus)
+	return &repo.withs[len(repo.withs)-1]
+}
+
+func fetchNexus(m interface{}) (data NexusValues, err error) {
+	if c, e := m.(Model).Repo().Collect(); e != nil {
+		err = e
+	} else {
+		data = &DefaultNexusValues{data: c}
+	}
+	return
+}
+
+// constrain apply the constraints of w to the target repo
+func (w with) constrain(r *Repo) {
+	for _, constraint := range w.constraints {
+		constraint(r)
+	}
 }
 
 // nexusValues fetch all nexus result according the repo fetch result
@@ -96,30 +222,25 @@ func (repo *Repo) nexusValues(models []interface{}) (result []nexusResult, err e
 		return
 	}
 	for _, w := range repo.withs {
-		if w.t == t_bad {
-			err = &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + w.name + " not exists")}
-			return
-		}
-		nm := New(w.m)
-		r := nm.(Model).Repo()
-		for af, bf := range w.n {
-			switch bf.(type) {
-			case NWhere:
-				r.Where(af, bf.(NWhere).Op, bf.(NWhere).Value)
-			case string:
-				vals := []interface{}{}
-				for _, m := range models {
-					if val, e := repo.model.(Mapable).Mapper().colValue(m, bf.(string)); e != nil {
-						err = e
-						return
-					} else {
-						vals = append(vals, val)
-					}
-				}
-				if len(vals) != 0 {
-					r.WhereIn(af, vals)
-				}
+		if w.t == t_pivot || w.t == t_morph || w.through != nil {
+			var data NexusValues
+			switch w.t {
+			case t_pivot:
+				data, err = repo.pivotValues(w, models)
+			case t_morph:
+				data, err = repo.morphValues(w, models)
+			default:
+				data, err = repo.throughValues(w, models)
 			}
+			if err != nil {
+				return
+			}
+			result = append(result, nexusResult{w.name, nil, w.n, w.t, data})
+			continue
+		}
+		var nm interface{}
+		if nm, err = repo.nexusModel(w, models); err != nil {
+			return
 		}
 		if data, e := w.handler(nm); e != nil {
 			err = e
@@ -132,32 +253,77 @@ func (repo *Repo) nexusValues(models []interface{}) (result []nexusResult, err e
 	return
 }
 
+// nexusModel new the nexus target model whose repo is conditioned to find
+// the nexus of models
+func (repo *Repo) nexusModel(w with, models []interface{}) (nm interface{}, err error) {
+	if w.t == t_bad || w.t == t_morph {
+		err = &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + w.name + " not exists")}
+		return
+	}
+	if w.t == t_pivot {
+		nm, _, err = repo.pivotModel(w, models)
+		return
+	}
+	if w.through != nil {
+		return repo.throughModel(w, models)
+	}
+	nm = New(w.m)
+	r := nm.(Model).Repo()
+	for af, bf := range w.n {
+		switch bf.(type) {
+		case NWhere:
+			r.Where(af, bf.(NWhere).Op, bf.(NWhere).Value)
+		case string:
+			vals := []interface{}{}
+			for _, m := range models {
+				if val, e := repo.model.(Mapable).Mapper().colValue(m, bf.(string)); e != nil {
+					err = e
+					return
+				} else {
+					vals = append(vals, val)
+				}
+			}
+			if len(vals) != 0 {
+				r.WhereIn(af, vals)
+			}
+		}
+	}
+	w.constrain(r)
+
+	return
+}
+
 //
 // bind nexus result to each fetched model
 //
 func (repo *Repo) bindNexus(m interface{}, nr []nexusResult) {
 	for _, n := range nr {
 		nm := n.data.DataOf(m, n.n)
-		if n.t == t_many {
+		if toMany(n.t) {
 			m.(NexusMany).SetMany(n.name, nm)
+			bindInverse(m, n.name, nm)
 			continue
 		}
-		ty := reflect.TypeOf(nm)
-		switch ty.Kind() {
-		case reflect.Map:
-			val := nm.(map[interface{}]interface{})
-			for _, item := range val {
-				m.(NexusOne).SetOne(n.name, item)
-				break
+		// an empty to-one nexus is bound as nil, so that it's loaded too
+		switch val := nm.(type) {
+		case Collection:
+			nm = nil
+			if len(val) != 0 {
+				nm = val[0]
 			}
-		case reflect.Slice:
-			val := nm.([]interface{})
+		case map[interface{}]interface{}:
+			nm = nil
 			for _, item := range val {
-				m.(NexusOne).SetOne(n.name, item)
+				nm = item
 				break
 			}
-		default:
-			m.(NexusOne).SetOne(n.name, nm)
+		case []interface{}:
+			nm = nil
+			if len(val) != 0 {
+				nm = val[0]
+			}
 		}
+		m.(NexusOne).SetOne(n.name, nm)
+		bindInverse(m, n.name, nm)
 	}
 }
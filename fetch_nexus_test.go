@@ -0,0 +1,51 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	. "github.com/yang-zzhong/go-querybuilder"
+	. "testing"
+	"time"
+)
+
+func TestNexusKey(t *T) {
+	if nexusKey(int64(1), "a") != nexusKey(1, []byte("a")) {
+		t.Fatal("nexus key of int64 and int error")
+	}
+	if nexusKey(uint8(2)) != nexusKey(float64(2)) || nexusKey(2.5) == nexusKey(2) {
+		t.Fatal("nexus key of uint and float error")
+	}
+	if nexusKey(sql.NullInt64{Int64: 3, Valid: true}) != nexusKey(3) || nexusKey(sql.NullInt64{}) != nexusKey(nil) {
+		t.Fatal("nexus key of valuer error")
+	}
+	at := time.Now()
+	if nexusKey(at) != nexusKey(at.In(time.FixedZone("east", 8*3600))) {
+		t.Fatal("nexus key of time error")
+	}
+}
+
+func TestDefaultNexusValues(t *T) {
+	data := make(map[interface{}]interface{})
+	for i, age := range []int{17, 17, 18} {
+		u := New(&FilterUser{Id: fmt.Sprint(i), Age: age}).(*FilterUser)
+		data[u.Id] = u
+	}
+	nv := NewNexusValues(data)
+	u := New(&FilterUser{Id: "17"}).(*FilterUser)
+	if result := nv.DataOf(u, Nexus{"age": "id", "id": NWhere{GT, 0}}); len(result.(Collection)) != 2 {
+		t.Fatal("data of nexus error")
+	}
+	u.Id = "19"
+	if result := nv.DataOf(u, Nexus{"age": "id"}); len(result.(Collection)) != 0 {
+		t.Fatal("data of no nexus error")
+	}
+}
+
+func TestBindEmptyOne(t *T) {
+	u := New(&FilterUser{Id: "1"}).(*FilterUser)
+	nv := NewNexusValues(make(map[interface{}]interface{}))
+	new(Repo).bindNexus(u, []nexusResult{{name: "profile", n: Nexus{"user_id": "id"}, t: t_one, data: nv}})
+	if !u.Loaded("profile") || u.MustOne("profile") != nil {
+		t.Fatal("bind empty one error")
+	}
+}
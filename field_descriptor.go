@@ -16,6 +16,7 @@ type fieldDescriptor struct {
 	isuk      bool
 	ispk      bool
 	isindex   bool
+	isdeleted bool // soft delete timestamp col
 }
 
 //
@@ -44,6 +45,7 @@ func newFd(fieldname string, src string) *fieldDescriptor {
 //    Id		int 	`db:"id int pk"`
 //    Title		string	`db:"title varchar(256) index"`
 //    AuthorId	int		`db:"author_id int index"`
+//    DeletedAt time.Time `db:"deleted_at datetime nil,softdelete"`
 // }
 //
 func (fd *fieldDescriptor) parse(src string) {
@@ -62,6 +64,8 @@ func (fd *fieldDescriptor) parse(src string) {
 				fd.isindex = true
 			case "protected":
 				fd.protected = true
+			case "softdelete":
+				fd.isdeleted = true
 			}
 		}
 	}
@@ -0,0 +1,297 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	. "github.com/yang-zzhong/go-querybuilder"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FILTER_SORT     = "sort"
+	FILTER_PAGE     = "page"
+	FILTER_PER_PAGE = "per_page"
+)
+
+// filter operators to sql operators
+var filterOps = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"gt":   ">",
+	"gte":  ">=",
+	"lt":   "<",
+	"lte":  "<=",
+	"like": "LIKE",
+	"in":   "IN",
+	"null": "NULL",
+}
+
+// Filter apply http query params to a repo. cols are validated by the model
+// mapper, protected or undefined cols are rejected, so a typo never drops a
+// condition silently. params other than cols are only the reserved sort,
+// page and per_page. values are converted to the field type of the col
+//
+//	?name=yang&age[gte]=18&id[in]=1,2,3&optional[null]=true&sort=-created_at,name&page=2&per_page=20
+type Filter struct {
+	values     url.Values
+	PerPage    int // per page when page given without per_page
+	MaxPerPage int // max per page accepted
+}
+
+// a col condition parsed from filter
+type condition struct {
+	col   string
+	op    string
+	value interface{}
+}
+
+// a col order parsed from filter
+type ordering struct {
+	col  string
+	desc bool
+}
+
+func NewFilter(values url.Values) *Filter {
+	return &Filter{values, 20, 100}
+}
+
+func NewFilterMap(values map[string]string) *Filter {
+	vals := url.Values{}
+	for key, val := range values {
+		vals.Set(key, val)
+	}
+	return NewFilter(vals)
+}
+
+// Apply add conditions, orders and pagination of the filter to repo
+func (filter *Filter) Apply(repo *Repo) error {
+	mapper := repo.model.(Mapable).Mapper()
+	conditions, err := filter.conditions(mapper)
+	if err != nil {
+		return err
+	}
+	orderings, err := filter.orderings(mapper)
+	if err != nil {
+		return err
+	}
+	limit, offset, err := filter.page()
+	if err != nil {
+		return err
+	}
+	for _, c := range conditions {
+		switch c.op {
+		case "IN":
+			repo.WhereIn(c.col, c.value.([]interface{}))
+		case "NULL":
+			if c.value.(bool) {
+				repo.WhereRaw(c.col + " IS NULL")
+			} else {
+				repo.WhereRaw(c.col + " IS NOT NULL")
+			}
+		default:
+			repo.Where(c.col, c.op, c.value)
+		}
+	}
+	for _, o := range orderings {
+		if o.desc {
+			repo.OrderBy(o.col, DESC)
+		} else {
+			repo.OrderBy(o.col, ASC)
+		}
+	}
+	if limit > 0 {
+		repo.Limit(limit)
+		repo.Offset(offset)
+	}
+
+	return nil
+}
+
+func (filter *Filter) conditions(mapper *ModelMapper) (result []condition, err error) {
+	keys := []string{}
+	for key, _ := range filter.values {
+		switch key {
+		case FILTER_SORT, FILTER_PAGE, FILTER_PER_PAGE:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		col, op := key, "eq"
+		if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+			col, op = key[:i], key[i+1:len(key)-1]
+		}
+		var fd *fieldDescriptor
+		if fd, err = filterFd(mapper, col); err != nil {
+			return
+		}
+		sqlop, ok := filterOps[op]
+		if !ok {
+			err = &Error{ERR_BAD_FILTER, errors.New("filter operator " + op + " undefined")}
+			return
+		}
+		for _, raw := range filter.values[key] {
+			c := condition{col: col, op: sqlop}
+			switch sqlop {
+			case "IN":
+				vals := []interface{}{}
+				for _, item := range strings.Split(raw, ",") {
+					var val interface{}
+					if val, err = filterValue(mapper, fd, item); err != nil {
+						return
+					}
+					vals = append(vals, val)
+				}
+				c.value = vals
+			case "NULL":
+				if c.value, err = strconv.ParseBool(raw); err != nil {
+					err = &Error{ERR_BAD_FILTER, errors.New("bad null filter of " + col)}
+					return
+				}
+			case "LIKE":
+				c.value = raw
+			default:
+				if c.value, err = filterValue(mapper, fd, raw); err != nil {
+					return
+				}
+			}
+			result = append(result, c)
+		}
+	}
+
+	return
+}
+
+func (filter *Filter) orderings(mapper *ModelMapper) (result []ordering, err error) {
+	raw := filter.values.Get(FILTER_SORT)
+	if raw == "" {
+		return
+	}
+	for _, item := range strings.Split(raw, ",") {
+		o := ordering{col: strings.Trim(item, " ")}
+		if strings.HasPrefix(o.col, "-") {
+			o.col = o.col[1:]
+			o.desc = true
+		}
+		if _, err = filterFd(mapper, o.col); err != nil {
+			return
+		}
+		result = append(result, o)
+	}
+
+	return
+}
+
+func (filter *Filter) page() (limit, offset int, err error) {
+	page := filter.values.Get(FILTER_PAGE)
+	perPage := filter.values.Get(FILTER_PER_PAGE)
+	if page == "" && perPage == "" {
+		return
+	}
+	p, pp := 1, filter.PerPage
+	if page != "" {
+		if p, err = strconv.Atoi(page); err != nil || p < 1 {
+			err = &Error{ERR_BAD_FILTER, errors.New("bad page " + page)}
+			return
+		}
+	}
+	if perPage != "" {
+		if pp, err = strconv.Atoi(perPage); err != nil || pp < 1 {
+			err = &Error{ERR_BAD_FILTER, errors.New("bad per page " + perPage)}
+			return
+		}
+	}
+	if filter.MaxPerPage > 0 && pp > filter.MaxPerPage {
+		pp = filter.MaxPerPage
+	}
+	limit, offset = pp, (p-1)*pp
+
+	return
+}
+
+// filterFd find the fd of a filterable col
+func filterFd(mapper *ModelMapper, col string) (*fieldDescriptor, error) {
+	fd, ok := mapper.fd(col)
+	if !ok {
+		return nil, &Error{ERR_COL_UNDEFINED, errors.New("col " + col + " undefined")}
+	}
+	if fd.protected {
+		return nil, &Error{ERR_BAD_FILTER, errors.New("col " + col + " is protected")}
+	}
+	return fd, nil
+}
+
+// filterValue convert a raw filter value to the field type of the col
+func filterValue(mapper *ModelMapper, fd *fieldDescriptor, raw string) (value interface{}, err error) {
+	field := mapper.value.FieldByName(fd.fieldname)
+	t := field.Type()
+	switch field.Interface().(type) {
+	case time.Time:
+		value, err = parseTime(raw)
+	case NullTime:
+		var at time.Time
+		at, err = parseTime(raw)
+		value = NullTime{Time: at, Valid: true}
+	case sql.NullString:
+		value = sql.NullString{String: raw, Valid: true}
+	case sql.NullInt64:
+		var i int64
+		i, err = strconv.ParseInt(raw, 10, 64)
+		value = sql.NullInt64{Int64: i, Valid: true}
+	case sql.NullFloat64:
+		var f float64
+		f, err = strconv.ParseFloat(raw, 64)
+		value = sql.NullFloat64{Float64: f, Valid: true}
+	case sql.NullBool:
+		var b bool
+		b, err = strconv.ParseBool(raw)
+		value = sql.NullBool{Bool: b, Valid: true}
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			value = reflect.ValueOf(raw).Convert(t).Interface()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var i int64
+			if i, err = strconv.ParseInt(raw, 10, t.Bits()); err == nil {
+				value = reflect.ValueOf(i).Convert(t).Interface()
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var u uint64
+			if u, err = strconv.ParseUint(raw, 10, t.Bits()); err == nil {
+				value = reflect.ValueOf(u).Convert(t).Interface()
+			}
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			if f, err = strconv.ParseFloat(raw, t.Bits()); err == nil {
+				value = reflect.ValueOf(f).Convert(t).Interface()
+			}
+		case reflect.Bool:
+			value, err = strconv.ParseBool(raw)
+		default:
+			err = errors.New("unfilterable type")
+		}
+	}
+	if err != nil {
+		return nil, &Error{ERR_BAD_FILTER, errors.New("bad value " + raw + " of col " + fd.colname)}
+	}
+	if converter, ok := mapper.model.(ValueConverter); ok {
+		value = converter.DBValue(fd.colname, value)
+	}
+
+	return
+}
+
+func parseTime(raw string) (at time.Time, err error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if at, err = time.Parse(layout, raw); err == nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"net/url"
+	. "testing"
+	"time"
+)
+
+type FilterUser struct {
+	Id        string    `db:"id | varchar(128) | pk"`
+	Age       int       `db:"age | int"`
+	Password  string    `db:"password | varchar(128) | protected"`
+	CreatedAt time.Time `db:"created_at | datetime"`
+	*Base
+}
+
+func (u *FilterUser) TableName() string {
+	return "users"
+}
+
+func TestFilterConditions(t *T) {
+	mapper := New(new(FilterUser)).(Mapable).Mapper()
+	values, _ := url.ParseQuery("age[gte]=18&id[in]=1,2&created_at[lt]=2018-01-02&sort=-age&page=2&per_page=10")
+	conditions, err := NewFilter(values).conditions(mapper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conditions) != 3 {
+		t.Fatal("filter conditions error")
+	}
+	if c := conditions[0]; c.col != "age" || c.op != ">=" || c.value != 18 {
+		t.Fatal("filter gte error")
+	}
+	if c := conditions[1]; c.op != "<" || !c.value.(time.Time).Equal(time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("filter time error")
+	}
+	if c := conditions[2]; c.op != "IN" || len(c.value.([]interface{})) != 2 {
+		t.Fatal("filter in error")
+	}
+	orderings, err := NewFilter(values).orderings(mapper)
+	if err != nil || len(orderings) != 1 || !orderings[0].desc {
+		t.Fatal("filter sort error")
+	}
+}
+
+func TestFilterReject(t *T) {
+	mapper := New(new(FilterUser)).(Mapable).Mapper()
+	for _, query := range []string{
+		"password=123",
+		"unknown=1",
+		"agee[gte]=18",
+		"age[bad]=1",
+		"age=eighteen",
+		"sort=password",
+	} {
+		values, _ := url.ParseQuery(query)
+		filter := NewFilter(values)
+		_, err := filter.conditions(mapper)
+		if err == nil {
+			_, err = filter.orderings(mapper)
+		}
+		if err == nil || !IsModelErr(err) {
+			t.Fatalf("filter should reject %v", query)
+		}
+	}
+}
+
+func TestFilterPage(t *T) {
+	limit, offset, err := NewFilterMap(map[string]string{"page": "3", "per_page": "500"}).page()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit != 100 || offset != 200 {
+		t.Fatal("filter page error")
+	}
+}
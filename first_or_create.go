@@ -0,0 +1,114 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// FirstOrNew find the first model matching attrs, if not found, new one with
+// attrs and defaults without saving it
+func (repo *Repo) FirstOrNew(attrs, defaults map[string]interface{}) (interface{}, error) {
+	if m, ok, err := repo.firstOf(attrs); err != nil || ok {
+		return m, err
+	}
+	return repo.newWith(attrs, defaults)
+}
+
+// FirstOrCreate find the first model matching attrs, if not found, create one
+// with attrs and defaults. if a concurrent caller created it first, the
+// created one will be read. inside a tx the create runs in a savepoint, so
+// the tx survives the unique violation
+func (repo *Repo) FirstOrCreate(attrs, defaults map[string]interface{}) (m interface{}, err error) {
+	db := repo.model.(Model).DB()
+	err = db.savepoint("first_or_create", func(_ *sql.Tx) error {
+		var ok bool
+		var e error
+		if m, ok, e = repo.firstOf(attrs); e != nil || ok {
+			return e
+		}
+		if m, e = repo.newWith(attrs, defaults); e != nil {
+			return e
+		}
+		return repo.Create(m)
+	})
+	if IsUniqueViolation(err) {
+		return repo.mustFirstOf(attrs)
+	}
+
+	return
+}
+
+// UpdateOrCreate update the first model matching attrs with values, if not
+// found, create one with attrs and values. inside a tx it runs in a savepoint
+// like FirstOrCreate
+func (repo *Repo) UpdateOrCreate(attrs, values map[string]interface{}) (m interface{}, err error) {
+	db := repo.model.(Model).DB()
+	handle := func(_ *sql.Tx) error {
+		var ok bool
+		var e error
+		if m, ok, e = repo.firstOf(attrs); e != nil {
+			return e
+		} else if ok {
+			for col, val := range values {
+				if val == nil {
+					continue
+				}
+				if e = m.(pushable).assign(col, val); e != nil {
+					return e
+				}
+			}
+			return repo.Update(m)
+		}
+		if m, e = repo.newWith(attrs, values); e != nil {
+			return e
+		}
+		return repo.Create(m)
+	}
+	// a concurrent caller created it first, update it then
+	if err = db.savepoint("update_or_create", handle); IsUniqueViolation(err) {
+		err = db.savepoint("update_or_create", handle)
+	}
+
+	return
+}
+
+func (repo *Repo) firstOf(attrs map[string]interface{}) (interface{}, bool, error) {
+	r := repo.Another()
+	mapper := repo.model.(Mapable).Mapper()
+	for col, val := range attrs {
+		if !mapper.has(col) {
+			return nil, false, &Error{ERR_COL_UNDEFINED, errors.New("col " + col + " undefined")}
+		}
+		r.Where(col, val)
+	}
+	r.Limit(1)
+
+	return r.One()
+}
+
+func (repo *Repo) mustFirstOf(attrs map[string]interface{}) (interface{}, error) {
+	m, ok, err := repo.firstOf(attrs)
+	if err == nil && !ok {
+		err = &Error{ERR_DATA_NOT_FOUND, errors.New("data not found")}
+	}
+
+	return m, err
+}
+
+// newWith new a model filled with defaults and attrs, attrs override defaults.
+// the values are converted to the field types, an inconvertible one is an error
+func (repo *Repo) newWith(attrs, defaults map[string]interface{}) (interface{}, error) {
+	m := newOf(repo.model)
+	for _, data := range []map[string]interface{}{defaults, attrs} {
+		for col, val := range data {
+			if val == nil {
+				continue
+			}
+			if err := m.(pushable).assign(col, val); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	. "testing"
+)
+
+func TestNewWith(t *T) {
+	repo := &Repo{model: New(new(FilterUser))}
+	m, err := repo.newWith(map[string]interface{}{"id": 1}, map[string]interface{}{"age": float64(18)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := m.(*FilterUser); u.Id != "1" || u.Age != 18 {
+		t.Fatal("new with converted values error")
+	}
+	if _, err = repo.newWith(map[string]interface{}{"age": "eighteen"}, nil); err == nil {
+		t.Fatal("new with inconvertible value error")
+	}
+	if _, err = repo.newWith(map[string]interface{}{"undefined": 1}, nil); err == nil {
+		t.Fatal("new with undefined col error")
+	}
+}
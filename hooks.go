@@ -0,0 +1,367 @@
+package model
+
+import (
+	"database/sql"
+	"reflect"
+	"sync"
+)
+
+// model lifecycle event
+type Event int
+
+const (
+	BEFORE_CREATE Event = iota
+	AFTER_CREATE
+	BEFORE_UPDATE
+	AFTER_UPDATE
+	BEFORE_SAVE
+	AFTER_SAVE
+	BEFORE_DELETE
+	AFTER_DELETE
+	AFTER_FETCH
+)
+
+// events fired by each kind of write, in order
+var (
+	before_create = []Event{BEFORE_SAVE, BEFORE_CREATE}
+	after_create  = []Event{AFTER_CREATE, AFTER_SAVE}
+	before_update = []Event{BEFORE_SAVE, BEFORE_UPDATE}
+	after_update  = []Event{AFTER_UPDATE, AFTER_SAVE}
+	before_delete = []Event{BEFORE_DELETE}
+	after_delete  = []Event{AFTER_DELETE}
+)
+
+// hooks implemented by model, an error aborts the write
+type BeforeCreator interface {
+	BeforeCreate() error
+}
+
+type AfterCreator interface {
+	AfterCreate() error
+}
+
+type BeforeUpdater interface {
+	BeforeUpdate() error
+}
+
+type AfterUpdater interface {
+	AfterUpdate() error
+}
+
+type BeforeSaver interface {
+	BeforeSave() error
+}
+
+type AfterSaver interface {
+	AfterSave() error
+}
+
+type BeforeDeleter interface {
+	BeforeDelete() error
+}
+
+type AfterDeleter interface {
+	AfterDelete() error
+}
+
+type AfterFetcher interface {
+	AfterFetch() error
+}
+
+// hook set by OnCreate, OnUpdate or OnDelete of model
+type baseHooked interface {
+	hookOf(event Event) modify
+}
+
+// a registered listener, compared by pointer to unlisten
+type listener struct {
+	handle modify
+}
+
+var (
+	listeners  map[reflect.Type]map[Event][]*listener
+	listenLock sync.RWMutex
+)
+
+func init() {
+	listeners = make(map[reflect.Type]map[Event][]*listener)
+}
+
+// Listen register handle of event for models of the type of m, listeners run
+// in the order of registration after the hooks of model. the returned func
+// unregister the listener
+//
+//	unlisten := model.Listen(new(User), model.AFTER_CREATE, func(m interface{}) error {
+//		return notify(m.(*User))
+//	})
+//	defer unlisten()
+func Listen(m interface{}, event Event, handle modify) (unlisten func()) {
+	t := reflect.TypeOf(m)
+	l := &listener{handle}
+	listenLock.Lock()
+	defer listenLock.Unlock()
+	if listeners[t] == nil {
+		listeners[t] = make(map[Event][]*listener)
+	}
+	listeners[t][event] = append(listeners[t][event], l)
+
+	return func() {
+		listenLock.Lock()
+		defer listenLock.Unlock()
+		kept := []*listener{}
+		for _, other := range listeners[t][event] {
+			if other != l {
+				kept = append(kept, other)
+			}
+		}
+		listeners[t][event] = kept
+	}
+}
+
+// listenersOf find the listeners of event for models of the type of m
+func listenersOf(m interface{}, event Event) []*listener {
+	listenLock.RLock()
+	defer listenLock.RUnlock()
+	return listeners[reflect.TypeOf(m)][event]
+}
+
+// hook run the hook of event implemented by model
+func hook(model interface{}, event Event) error {
+	switch event {
+	case BEFORE_CREATE:
+		if h, ok := model.(BeforeCreator); ok {
+			return h.BeforeCreate()
+		}
+	case AFTER_CREATE:
+		if h, ok := model.(AfterCreator); ok {
+			return h.AfterCreate()
+		}
+	case BEFORE_UPDATE:
+		if h, ok := model.(BeforeUpdater); ok {
+			return h.BeforeUpdate()
+		}
+	case AFTER_UPDATE:
+		if h, ok := model.(AfterUpdater); ok {
+			return h.AfterUpdate()
+		}
+	case BEFORE_SAVE:
+		if h, ok := model.(BeforeSaver); ok {
+			return h.BeforeSave()
+		}
+	case AFTER_SAVE:
+		if h, ok := model.(AfterSaver); ok {
+			return h.AfterSave()
+		}
+	case BEFORE_DELETE:
+		if h, ok := model.(BeforeDeleter); ok {
+			return h.BeforeDelete()
+		}
+	case AFTER_DELETE:
+		if h, ok := model.(AfterDeleter); ok {
+			return h.AfterDelete()
+		}
+	case AFTER_FETCH:
+		if h, ok := model.(AfterFetcher); ok {
+			return h.AfterFetch()
+		}
+	}
+	return nil
+}
+
+// hooked tell if model of the type of m has any hook of events
+func hooked(m interface{}, events ...Event) bool {
+	for _, event := range events {
+		implemented := false
+		switch event {
+		case BEFORE_CREATE:
+			_, implemented = m.(BeforeCreator)
+		case AFTER_CREATE:
+			_, implemented = m.(AfterCreator)
+		case BEFORE_UPDATE:
+			_, implemented = m.(BeforeUpdater)
+		case AFTER_UPDATE:
+			_, implemented = m.(AfterUpdater)
+		case BEFORE_SAVE:
+			_, implemented = m.(BeforeSaver)
+		case AFTER_SAVE:
+			_, implemented = m.(AfterSaver)
+		case BEFORE_DELETE:
+			_, implemented = m.(BeforeDeleter)
+		case AFTER_DELETE:
+			_, implemented = m.(AfterDeleter)
+		case AFTER_FETCH:
+			_, implemented = m.(AfterFetcher)
+		}
+		if implemented || len(listenersOf(m, event)) != 0 {
+			return true
+		}
+		if b, ok := m.(baseHooked); ok && b.hookOf(event) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// fire run the hooks of event of model, the hook implemented by model first,
+// then the hook set by OnCreate, OnUpdate or OnDelete, then the listeners
+func fire(model interface{}, event Event) error {
+	if err := hook(model, event); err != nil {
+		return err
+	}
+	if b, ok := model.(baseHooked); ok {
+		if h := b.hookOf(event); h != nil {
+			if err := h(model); err != nil {
+				return err
+			}
+		}
+	}
+	for _, l := range listenersOf(model, event) {
+		if err := l.handle(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (base *Base) hookOf(event Event) modify {
+	switch event {
+	case BEFORE_CREATE:
+		return base.oncreate
+	case BEFORE_UPDATE:
+		return base.onupdate
+	case BEFORE_DELETE:
+		return base.ondelete
+	}
+	return nil
+}
+
+func (repo *Repo) hookOf(event Event) modify {
+	switch event {
+	case BEFORE_CREATE:
+		return repo.oncreate
+	case BEFORE_UPDATE:
+		return repo.onupdate
+	case BEFORE_DELETE:
+		return repo.ondelete
+	}
+	return nil
+}
+
+// hooked tell if models of the repo have any hook of events, the hooks set
+// by OnCreate, OnUpdate or OnDelete of repo included
+func (repo *Repo) hooked(events ...Event) bool {
+	for _, event := range events {
+		if repo.hookOf(event) != nil {
+			return true
+		}
+	}
+	return hooked(repo.model, events...)
+}
+
+// before run the hooks of events of model before a write, the hook set by
+// OnCreate, OnUpdate or OnDelete of repo first
+func (repo *Repo) before(model interface{}, events ...Event) error {
+	for _, event := range events {
+		if h := repo.hookOf(event); h != nil {
+			if err := h(model); err != nil {
+				return err
+			}
+		}
+		if err := fire(model, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after run the hooks of events of models after a write
+func (repo *Repo) after(models []interface{}, events ...Event) error {
+	for _, model := range models {
+		for _, event := range events {
+			if err := fire(model, event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write run the before hooks of models, write, then the after hooks of
+// models. write and the after hooks run in a tx if models have after hooks,
+// so that an error of them rolls back the write
+func (repo *Repo) write(models []interface{}, before, after []Event, write func() error) error {
+	for _, model := range models {
+		if err := repo.before(model, before...); err != nil {
+			return err
+		}
+	}
+	run := func() error {
+		if err := write(); err != nil {
+			return err
+		}
+		return repo.after(models, after...)
+	}
+	if !repo.hooked(after...) {
+		return run()
+	}
+	return repo.model.(Model).DB().transaction(func(_ *sql.Tx) error {
+		return run()
+	})
+}
+
+// writeMatched find the pks of the rows matched by repo, fetch the models of
+// them for their hooks, then run the before hooks, write the rows by their
+// pks, and run the after hooks of the models fetched again if refetch, in a
+// tx. soft deleted rows are matched too like a raw write without hooks
+func (repo *Repo) writeMatched(before, after []Event, refetch bool, write func(r *Repo) error) error {
+	return repo.model.(Model).DB().transaction(func(_ *sql.Tx) error {
+		ids, err := repo.matched()
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		pk := repo.model.(Model).PK()
+		r := repo.Another().WithTrashed()
+		r.WhereIn(pk, ids)
+		models, err := r.Fetch()
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			if err = repo.before(model, before...); err != nil {
+				return err
+			}
+		}
+		r = repo.Another().WithTrashed()
+		r.WhereIn(pk, ids)
+		if err = write(r); err != nil {
+			return err
+		}
+		if refetch {
+			r = repo.Another().WithTrashed()
+			r.WhereIn(pk, ids)
+			if models, err = r.Fetch(); err != nil {
+				return err
+			}
+		}
+		return repo.after(models, after...)
+	})
+}
+
+// matched find the pks of the rows matched by repo, with the joins and the
+// soft delete scope applied the same way as a fetch
+func (repo *Repo) matched() (ids []interface{}, err error) {
+	pk := repo.model.(Model).PK()
+	sqlang, params, err := repo.matchedQuery()
+	if err != nil {
+		return nil, err
+	}
+	err = repo.query(sqlang, params, func(rows *sql.Rows, columns []string) error {
+		row, err := scanMap(rows, columns)
+		if err == nil {
+			ids = append(ids, row[pk])
+		}
+		return err
+	})
+
+	return
+}
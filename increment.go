@@ -0,0 +1,148 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Expr is a raw sql fragment with it's params, used as an update value
+type Expr struct {
+	Raw    string
+	Params []interface{}
+}
+
+func NewExpr(raw string, params ...interface{}) Expr {
+	return Expr{raw, params}
+}
+
+// Increment add n to col of rows matching the repo
+func (repo *Repo) Increment(col string, n interface{}) error {
+	return repo.UpdateExpr(map[string]interface{}{col: NewExpr(col+" + ?", n)})
+}
+
+// Decrement subtract n from col of rows matching the repo
+func (repo *Repo) Decrement(col string, n interface{}) error {
+	return repo.UpdateExpr(map[string]interface{}{col: NewExpr(col+" - ?", n)})
+}
+
+// UpdateExpr update rows matching the repo with values, an Expr value is
+// written as it's raw sql with params, others as literal values. the matched
+// rows are fetched for the update hooks if models have any
+//
+//	repo.UpdateExpr(map[string]interface{}{
+//	    "views": NewExpr("views + ?", 1),
+//	    "score": NewExpr("score * ? + ?", 2, 1),
+//	    "level": 3,
+//	})
+func (repo *Repo) UpdateExpr(values map[string]interface{}) error {
+	if !repo.hooked(append(before_update, after_update...)...) {
+		return repo.updateExpr(values)
+	}
+	return repo.writeMatched(before_update, after_update, true, func(r *Repo) error {
+		return r.updateExpr(values)
+	})
+}
+
+func (repo *Repo) updateExpr(values map[string]interface{}) error {
+	mapper := repo.model.(Mapable).Mapper()
+	cols := []string{}
+	for col, _ := range values {
+		if !mapper.has(col) {
+			return &Error{ERR_COL_UNDEFINED, errors.New("col " + col + " undefined")}
+		}
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	sets := []string{}
+	params := []interface{}{}
+	for _, col := range cols {
+		switch value := values[col].(type) {
+		case Expr:
+			sets = append(sets, col+" = "+value.Raw)
+			params = append(params, value.Params...)
+		default:
+			sets = append(sets, col+" = ?")
+			params = append(params, value)
+		}
+	}
+	// the matched rows are selected by a derived table so that mysql can
+	// update the table it selects from
+	query, where, err := repo.matchedQuery()
+	if err != nil {
+		return err
+	}
+	pk := repo.model.(Model).PK()
+	dialect := dialectOf(repo.modifier)
+	sqlang := "UPDATE " + repo.QuotedTableName() +
+		" SET " + bind(dialect, strings.Join(sets, ", "), len(where)) +
+		" WHERE " + repo.model.(Model).TableName() + "." + pk + " IN (" + query + ")"
+	db := repo.model.(Model).DB()
+	_, err = db.Exec(sqlang, bindArgs(dialect, params, where)...)
+
+	return err
+}
+
+// Increment add n to col of the model, the col is refreshed from db after
+func (base *Base) Increment(col string, n interface{}) error {
+	return base.UpdateExpr(map[string]interface{}{col: NewExpr(col+" + ?", n)})
+}
+
+// Decrement subtract n from col of the model, the col is refreshed from db after
+func (base *Base) Decrement(col string, n interface{}) error {
+	return base.UpdateExpr(map[string]interface{}{col: NewExpr(col+" - ?", n)})
+}
+
+// UpdateExpr update the model with values, the cols are refreshed from db after
+func (base *Base) UpdateExpr(values map[string]interface{}) error {
+	pk := base.PK()
+	id, err := base.fieldValue(pk)
+	if err != nil {
+		return err
+	}
+	r := base.Repo().Another().WithTrashed()
+	r.Where(pk, id)
+	cols := []string{}
+	for col, _ := range values {
+		cols = append(cols, col)
+	}
+	models := []interface{}{base.mapper.model}
+	return r.write(models, before_update, after_update, func() error {
+		if err := r.updateExpr(values); err != nil {
+			return err
+		}
+		return base.refresh(cols...)
+	})
+}
+
+// refresh read cols of the model from db again
+func (base *Base) refresh(cols ...string) error {
+	pk := base.PK()
+	id, err := base.fieldValue(pk)
+	if err != nil {
+		return err
+	}
+	fields := []interface{}{}
+	for _, col := range cols {
+		fields = append(fields, col)
+	}
+	r := base.Repo().Another().WithTrashed()
+	r.Select(fields...)
+	r.Where(pk, id)
+	found := false
+	err = r.fetch(func(m interface{}, _ interface{}) error {
+		found = true
+		values := base.mapper.modelValue(m)
+		for _, col := range cols {
+			if fd, ok := base.mapper.fd(col); ok {
+				base.mapper.value.FieldByName(fd.fieldname).Set(values.FieldByName(fd.fieldname))
+			}
+		}
+		return nil
+	})
+	if err == nil && !found {
+		err = &Error{ERR_DATA_NOT_FOUND, errors.New("data not found")}
+	}
+
+	return err
+}
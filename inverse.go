@@ -0,0 +1,46 @@
+package model
+
+// find the inverse nexus declared by model
+type inverted interface {
+	inverseOf(name string) (string, bool)
+}
+
+// DeclareInverse declare nexus inverse of the targets of nexus name refers
+// back to model, so loading name sets model as inverse of each target
+// without another query, if inverse is a has one nexus of the target
+//
+//	user.DeclareMany("books", new(Book), Nexus{"user_id": "id"})
+//	user.DeclareInverse("books", "author")
+func (base *Base) DeclareInverse(name string, inverse string) {
+	base.inverses[name] = inverse
+}
+
+func (base *Base) inverseOf(name string) (inverse string, ok bool) {
+	inverse, ok = base.inverses[name]
+	return
+}
+
+// bindInverse set m as the inverse nexus of the targets in value of nexus name
+func bindInverse(m interface{}, name string, value interface{}) {
+	i, ok := m.(inverted)
+	if !ok || value == nil {
+		return
+	}
+	inverse, ok := i.inverseOf(name)
+	if !ok {
+		return
+	}
+	targets := nexusItems(value)
+	if targets == nil {
+		targets = []interface{}{value}
+	}
+	for _, target := range targets {
+		r, ok := target.(relational)
+		if !ok {
+			continue
+		}
+		if rel, ok := r.relation(inverse); ok && !toMany(rel.t) {
+			target.(NexusOne).SetOne(inverse, m)
+		}
+	}
+}
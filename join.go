@@ -0,0 +1,191 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	. "github.com/yang-zzhong/go-querybuilder"
+	"sort"
+	"strings"
+)
+
+// prefixes of the cols selected by the repo itself as extras of the repo
+// model, like through__col, left out of Map
+const (
+	through_prefix = "through__"
+	tree_prefix    = "tree__"
+)
+
+// internal tell if extra name is selected by the repo itself
+func internal(name string) bool {
+	return strings.HasPrefix(name, through_prefix) || strings.HasPrefix(name, tree_prefix)
+}
+
+// a has one relationship fetched by left join
+type join struct {
+	name string      // relationship name
+	m    interface{} // relationship target
+	n    Nexus       // relationship nexus
+	t    int         // relationship type, must be t_one
+}
+
+// a part of the selected cols, packed to the repo model or a joined model,
+// or set as extras of the repo model if mapper is nil
+type part struct {
+	name    string // joined nexus name, empty for the repo model
+	mapper  *ModelMapper
+	columns []string
+	cols    []interface{}
+}
+
+// JoinWith tell repo to fetch has one nexus name by a left join in the same
+// query. the joined table is aliased as name, cols of it can be used in
+// conditions and orders by the alias
+//
+//	repo := book.Repo().JoinWith("author")
+//	repo.Where("author.age", ">", 18)
+//	books := repo.MustFetch()
+func (repo *Repo) JoinWith(name string) *Repo {
+	m, n, t := repo.nexus(name)
+	repo.joins = append(repo.joins, join{name, m, n, t})
+	return repo
+}
+
+// join apply the left joins and select cols of the repo model and each
+// joined model, cols of joined model are aliased as name__col. the nexus
+// must join by one col, its other conditions filter the joined table only
+func (repo *Repo) join() error {
+	if len(repo.joins) == 0 {
+		return nil
+	}
+	dialect := dialectOf(repo.modifier)
+	table := repo.model.(Model).TableName()
+	fields := []interface{}{}
+	repo.model.(Mapable).Mapper().each(func(fd *fieldDescriptor) bool {
+		fields = append(fields, E{table + "." + fd.colname + " AS " + fd.colname})
+		return true
+	})
+	for _, j := range repo.joins {
+		if j.t != t_one {
+			return &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + j.name + " not a has one nexus")}
+		}
+		mapper := newOf(j.m).(Mapable).Mapper()
+		cols := []string{}
+		for af := range j.n {
+			cols = append(cols, af)
+		}
+		sort.Strings(cols)
+		var first, second string
+		conds := []string{}
+		for _, af := range cols {
+			switch v := j.n[af].(type) {
+			case NWhere:
+				offset := len(repo.Params()) + len(repo.joinArgs)
+				conds = append(conds, bind(dialect, af+" "+v.Op+" ?", offset))
+				repo.joinArgs = append(repo.joinArgs, v.Value)
+			case string:
+				if first != "" {
+					return &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + j.name + " joins more than one col")}
+				}
+				first, second = j.name+"."+af, table+"."+v
+			}
+		}
+		if first == "" {
+			return &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + j.name + " joins no col")}
+		}
+		if mapper.deleted != "" {
+			conds = append(conds, mapper.deleted+" IS NULL")
+		}
+		// the conditions on the target alone filter it in a derived table,
+		// so rows without a matched target are still kept by the left join
+		target := mapper.model.(Model).TableName()
+		if len(conds) != 0 {
+			target = "(SELECT * FROM " + target + " WHERE " + strings.Join(conds, " AND ") + ")"
+		}
+		repo.LeftJoin(target+" AS "+quote(dialect, j.name), first, "=", second)
+		mapper.each(func(fd *fieldDescriptor) bool {
+			fields = append(fields, E{j.name + "." + fd.colname + " AS " + j.name + "__" + fd.colname})
+			return true
+		})
+	}
+	repo.Select(fields...)
+
+	return nil
+}
+
+// parts split columns to the repo model part, joined model parts and the
+// extras part, and make the scan pointers of columns. cols aliased as
+// name__col of a joined nexus go to the joined model part, cols prefixed by
+// an extras prefix of repo, like through__col, are extras
+func (repo *Repo) parts(columns []string) (pointers []interface{}, parts []*part, err error) {
+	parts = []*part{{mapper: repo.model.(Mapable).Mapper()}}
+	for _, j := range repo.joins {
+		parts = append(parts, &part{name: j.name, mapper: newOf(j.m).(Mapable).Mapper()})
+	}
+	extras := &part{}
+	owners := make([]*part, len(columns))
+	indexes := make([]int, len(columns))
+	for i, colname := range columns {
+		owners[i] = parts[0]
+		for _, prefix := range repo.extras {
+			if strings.HasPrefix(colname, prefix) {
+				owners[i] = extras
+			}
+		}
+		for _, p := range parts[1:] {
+			if strings.HasPrefix(colname, p.name+"__") {
+				owners[i] = p
+				colname = colname[len(p.name)+2:]
+				break
+			}
+		}
+		indexes[i] = len(owners[i].columns)
+		owners[i].columns = append(owners[i].columns, colname)
+	}
+	for i, p := range parts {
+		if p.cols, err = p.mapper.colsOf(p.columns, i != 0); err != nil {
+			return
+		}
+	}
+	if len(extras.columns) != 0 {
+		for range extras.columns {
+			extras.cols = append(extras.cols, new(interface{}))
+		}
+		parts = append(parts, extras)
+	}
+	pointers = make([]interface{}, len(columns))
+	for i, p := range owners {
+		pointers[i] = p.cols[indexes[i]]
+	}
+
+	return
+}
+
+// extra set the extras part cols as extras of m
+func (p *part) extra(m interface{}) {
+	for i, colname := range p.columns {
+		value := *(p.cols[i].(*interface{}))
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		m.(Extendable).SetExtra(colname, value)
+	}
+}
+
+// pack pack the joined model, nil if not joined or soft deleted
+func (p *part) pack() (interface{}, error) {
+	pk := p.mapper.model.(Model).PK()
+	for i, colname := range p.columns {
+		if colname != pk {
+			continue
+		}
+		if value, err := p.cols[i].(driver.Valuer).Value(); err != nil || value == nil {
+			return nil, err
+		}
+	}
+	m, _, err := p.mapper.packOf(p.columns, p.cols, pk, true)
+	if err != nil || p.mapper.trashed(m) {
+		return nil, err
+	}
+
+	return m, nil
+}
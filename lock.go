@@ -0,0 +1,71 @@
+package model
+
+const (
+	lock_none   = 0 // no row lock
+	lock_update = 1 // exclusive row lock
+	lock_share  = 2 // shared row lock
+)
+
+const (
+	lock_wait        = 0 // wait for rows locked by others
+	lock_skip_locked = 1 // skip rows locked by others
+	lock_nowait      = 2 // fail on rows locked by others
+)
+
+// LockForUpdate tell repo to lock the fetched rows exclusively until the tx
+// ends, the query must run in a tx of the model db
+func (repo *Repo) LockForUpdate() *Repo {
+	repo.lock = lock_update
+	return repo
+}
+
+// SharedLock tell repo to lock the fetched rows in share mode until the tx
+// ends, the query must run in a tx of the model db
+func (repo *Repo) SharedLock() *Repo {
+	repo.lock = lock_share
+	return repo
+}
+
+// SkipLocked tell repo to skip the rows locked by others, LockForUpdate if
+// no lock declared
+func (repo *Repo) SkipLocked() *Repo {
+	if repo.lock == lock_none {
+		repo.lock = lock_update
+	}
+	repo.lockOpt = lock_skip_locked
+	return repo
+}
+
+// NoWait tell repo to fail at once on the rows locked by others,
+// LockForUpdate if no lock declared
+func (repo *Repo) NoWait() *Repo {
+	if repo.lock == lock_none {
+		repo.lock = lock_update
+	}
+	repo.lockOpt = lock_nowait
+	return repo
+}
+
+// lockClause generate the row lock clause of select, sqlite has no row lock
+// and locks the whole db in a tx, so nothing generated for it
+func lockClause(dialect, lock, opt int) string {
+	if lock == lock_none || dialect == dialect_sqlite {
+		return ""
+	}
+	clause := " FOR UPDATE"
+	if lock == lock_share {
+		clause = " FOR SHARE"
+		if dialect == dialect_mysql && opt == lock_wait {
+			// compatible with mysql before 8.0
+			clause = " LOCK IN SHARE MODE"
+		}
+	}
+	switch opt {
+	case lock_skip_locked:
+		clause += " SKIP LOCKED"
+	case lock_nowait:
+		clause += " NOWAIT"
+	}
+
+	return clause
+}
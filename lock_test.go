@@ -0,0 +1,27 @@
+package model
+
+import (
+	. "testing"
+)
+
+func TestLockClause(t *T) {
+	cases := []struct {
+		dialect int
+		lock    int
+		opt     int
+		expect  string
+	}{
+		{dialect_mysql, lock_none, lock_wait, ""},
+		{dialect_mysql, lock_update, lock_wait, " FOR UPDATE"},
+		{dialect_mysql, lock_share, lock_wait, " LOCK IN SHARE MODE"},
+		{dialect_mysql, lock_share, lock_nowait, " FOR SHARE NOWAIT"},
+		{dialect_pgsql, lock_update, lock_skip_locked, " FOR UPDATE SKIP LOCKED"},
+		{dialect_pgsql, lock_share, lock_wait, " FOR SHARE"},
+		{dialect_sqlite, lock_update, lock_wait, ""},
+	}
+	for _, c := range cases {
+		if clause := lockClause(c.dialect, c.lock, c.opt); clause != c.expect {
+			t.Fatalf("lock clause error: %v", clause)
+		}
+	}
+}
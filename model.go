@@ -44,6 +44,12 @@ type NexusMany interface {
 	SetMany(name string, many interface{})              // set fetch result of has many relationship
 }
 
+// extra values attached to model, like aggregates of nexus
+type Extendable interface {
+	Extra(name string) interface{}
+	SetExtra(name string, value interface{})
+}
+
 type Mapable interface {
 	Mapper() *ModelMapper
 }
@@ -56,8 +62,24 @@ type ValueConverter interface {
 
 // relationship
 type relationship struct {
-	target interface{} // related with who
-	n      Nexus       // related
+	target  interface{} // related with who
+	n       Nexus       // related
+	t       int         // relationship type
+	pivot   *pivot      // pivot of a belongs to many relationship
+	morph   string      // col prefix of a morph to relationship
+	through *through    // through of a has many or has one through relationship
+}
+
+// pivot table of a belongs to many relationship
+type pivot struct {
+	table   string // pivot table name
+	local   Nexus  // pivot col to model col
+	foreign Nexus  // pivot col to target col
+}
+
+// relational find any kind of relationship declared, implemented by Base
+type relational interface {
+	relation(name string) (relationship, bool)
 }
 
 // base model struct
@@ -68,11 +90,17 @@ type Base struct {
 	dbSelector string
 	ones       map[string]relationship // has one relationship
 	manys      map[string]relationship // has many relationship
+	relations  map[string]relationship // other relationship like belongs to many
 	onesValue  map[string]interface{}  // fetched result of has one relationship
 	manysValue map[string]interface{}  // fetched result of has many relationship
-	oncreate   modify                  // declare in model_repo.go
-	onupdate   modify
-	ondelete   modify
+	extras     map[string]interface{}  // extra values like aggregates of nexus
+	pivotValue map[string]interface{}  // pivot row fetched with belongs to many relationship
+	policies   map[string]DeletePolicy // delete policy of relationship
+	tree       string                  // parent col of a tree node
+	inverses   map[string]string       // inverse nexus of the targets of relationship
+	oncreate   modify                  // before create hook of model
+	onupdate   modify                  // before update hook of model
+	ondelete   modify                  // before delete hook of model
 }
 
 // new a base model
@@ -83,13 +111,17 @@ func newBase(m interface{}) *Base {
 	base.mapper = NewModelMapper(m)
 	base.ones = make(map[string]relationship)
 	base.manys = make(map[string]relationship)
+	base.relations = make(map[string]relationship)
 	base.onesValue = make(map[string]interface{})
 	base.manysValue = make(map[string]interface{})
+	base.extras = make(map[string]interface{})
+	base.policies = make(map[string]DeletePolicy)
+	base.inverses = make(map[string]string)
 	return base
 }
 
 func (base *Base) OnCreate(m modify) {
-	base.Repo().oncreate = m
+	base.oncreate = m
 }
 
 func (base *Base) DB() *Db {
@@ -105,11 +137,11 @@ func (base *Base) SetDBSelector(selector string) {
 }
 
 func (base *Base) OnUpdate(m modify) {
-	base.Repo().onupdate = m
+	base.onupdate = m
 }
 
 func (base *Base) OnDelete(m modify) {
-	base.Repo().ondelete = m
+	base.ondelete = m
 }
 
 func (base *Base) IsFresh() bool {
@@ -121,11 +153,36 @@ func (base *Base) SetFresh(fresh bool) {
 }
 
 func (m *Base) DeclareOne(name string, one interface{}, n Nexus) {
-	m.ones[name] = relationship{one, n}
+	m.ones[name] = relationship{target: one, n: n, t: t_one}
 }
 
 func (base *Base) DeclareMany(name string, many interface{}, n Nexus) {
-	base.manys[name] = relationship{many, n}
+	base.manys[name] = relationship{target: many, n: n, t: t_many}
+}
+
+// DeclareBelongsToMany declare a many to many relationship through a pivot
+// table. local map pivot cols to model cols, foreign map pivot cols to
+// target cols
+//
+//	user.DeclareBelongsToMany("roles", new(Role), "user_role",
+//		Nexus{"user_id": "id"}, Nexus{"role_id": "id"})
+func (base *Base) DeclareBelongsToMany(name string, target interface{}, table string, local, foreign Nexus) {
+	base.relations[name] = relationship{
+		target: target,
+		t:      t_pivot,
+		pivot:  &pivot{table, local, foreign},
+	}
+}
+
+func (base *Base) relation(name string) (rel relationship, ok bool) {
+	if rel, ok = base.ones[name]; ok {
+		return
+	}
+	if rel, ok = base.manys[name]; ok {
+		return
+	}
+	rel, ok = base.relations[name]
+	return
 }
 
 func (base *Base) HasOne(name string) (one interface{}, n Nexus, has bool) {
@@ -162,7 +219,28 @@ func (base *Base) SetMany(name string, models interface{}) {
 	base.manysValue[name] = models
 }
 
+// Extra get the extra value attached to model, like books_count of WithCount("books")
+func (base *Base) Extra(name string) interface{} {
+	return base.extras[name]
+}
+
+func (base *Base) SetExtra(name string, value interface{}) {
+	base.extras[name] = value
+}
+
+// Pivot get the pivot row of model fetched by a belongs to many relationship
+func (base *Base) Pivot() map[string]interface{} {
+	return base.pivotValue
+}
+
+func (base *Base) SetPivot(row map[string]interface{}) {
+	base.pivotValue = row
+}
+
 func (base *Base) findOne(name string) (result interface{}, err error) {
+	if rel, ok := base.relation(name); ok && (rel.t == t_morph || rel.t == t_one_through) {
+		return base.load(name)
+	}
 	var one interface{}
 	var n Nexus
 	var has bool
@@ -190,6 +268,9 @@ func (base *Base) findOne(name string) (result interface{}, err error) {
 }
 
 func (base *Base) findMany(name string) (result interface{}, err error) {
+	if rel, ok := base.relation(name); ok && (rel.t == t_pivot || rel.t == t_many_through) {
+		return base.load(name)
+	}
 	var many interface{}
 	var rel Nexus
 	var has bool
@@ -210,11 +291,26 @@ func (base *Base) findMany(name string) (result interface{}, err error) {
 			repo.Where(af, value)
 		}
 	}
-	result, err = repo.FetchKey(m.PK())
+	result, err = repo.Collect()
 
 	return
 }
 
+// load fetch nexus name of the model the same way as eager load
+func (base *Base) load(name string) (result interface{}, err error) {
+	r := base.Repo().Another().With(name)
+	models := []interface{}{base.mapper.model}
+	if err = r.loadNexus(models); err != nil {
+		return
+	}
+	if rel, _ := base.relation(name); !toMany(rel.t) {
+		result = base.onesValue[name]
+	} else {
+		result = base.manysValue[name]
+	}
+	return
+}
+
 func (base *Base) fieldValue(field string) (value interface{}, err error) {
 	value, err = base.mapper.colValue(base.mapper.model, field)
 
@@ -239,6 +335,7 @@ func (base *Base) One(name string) (one interface{}, err error) {
 		return
 	}
 	one = base.onesValue[name]
+	bindInverse(base.mapper.model, name, one)
 	return
 }
 
@@ -267,6 +364,7 @@ func (base *Base) Many(name string) (many interface{}, err error) {
 		return
 	}
 	many = base.manysValue[name]
+	bindInverse(base.mapper.model, name, many)
 	return
 }
 
@@ -284,6 +382,11 @@ func (base *Base) Map() map[string]interface{} {
 		}
 		return true
 	})
+	for name, value := range base.extras {
+		if !internal(name) {
+			result[name] = value
+		}
+	}
 
 	return result
 }
@@ -300,6 +403,29 @@ func (base *Base) Delete() error {
 	return base.Repo().Delete(base.mapper.model)
 }
 
+func (base *Base) ForceDelete() error {
+	return base.Repo().ForceDelete(base.mapper.model)
+}
+
+func (base *Base) Restore() error {
+	return base.Repo().Restore(base.mapper.model)
+}
+
+// Trashed tell if the model is soft deleted
+func (base *Base) Trashed() bool {
+	return base.mapper.trashed(base.mapper.model)
+}
+
+// WithTrashed new a repo including soft deleted rows
+func (base *Base) WithTrashed() *Repo {
+	return base.Repo().Another().WithTrashed()
+}
+
+// OnlyTrashed new a repo finding soft deleted rows only
+func (base *Base) OnlyTrashed() *Repo {
+	return base.Repo().Another().OnlyTrashed()
+}
+
 func (base *Base) Save() error {
 	if base.fresh {
 		return base.Create()
@@ -358,3 +484,17 @@ func New(m interface{}) interface{} {
 	}
 	return m
 }
+
+// clone copy the cols of m to a new model
+func clone(m interface{}) interface{} {
+	value := reflect.New(reflect.TypeOf(m).Elem())
+	value.Elem().Set(reflect.ValueOf(m).Elem())
+	c := New(value.Interface())
+	c.(Model).SetFresh(m.(Model).IsFresh())
+	return c
+}
+
+// newOf new a model with the same type of m
+func newOf(m interface{}) interface{} {
+	return New(reflect.New(reflect.TypeOf(m).Elem()).Interface())
+}
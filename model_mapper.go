@@ -13,6 +13,7 @@ type ModelMapper struct {
 	model     interface{}
 	value     reflect.Value
 	pk        string
+	deleted   string // soft delete col
 	fds       map[string]*fieldDescriptor
 	field2col map[string]string
 }
@@ -37,6 +38,9 @@ func NewModelMapper(model interface{}) *ModelMapper {
 		if fd.ispk {
 			mm.pk = fd.colname
 		}
+		if fd.isdeleted {
+			mm.deleted = fd.colname
+		}
 	}
 
 	return mm
@@ -61,6 +65,11 @@ func (mm *ModelMapper) fd(colname string) (fd *fieldDescriptor, ok bool) {
 }
 
 func (mm *ModelMapper) cols(columns []string) (result []interface{}, err error) {
+	return mm.colsOf(columns, false)
+}
+
+// colsOf make scan pointers of columns, all nullable if nullable
+func (mm *ModelMapper) colsOf(columns []string, nullable bool) (result []interface{}, err error) {
 	pointers := make([]interface{}, len(columns))
 	var fd *fieldDescriptor
 	var ok bool
@@ -74,7 +83,7 @@ func (mm *ModelMapper) cols(columns []string) (result []interface{}, err error)
 		if converter, ok := mm.model.(ValueConverter); ok {
 			field = converter.DBValue(colname, field)
 		}
-		if fd.nullable {
+		if nullable || fd.nullable {
 			switch field.(type) {
 			case string:
 				pointers[i] = new(sql.NullString)
@@ -161,6 +170,11 @@ func (mm *ModelMapper) cols(columns []string) (result []interface{}, err error)
 }
 
 func (mm *ModelMapper) pack(columns []string, cols []interface{}, key string) (model interface{}, id interface{}, err error) {
+	return mm.packOf(columns, cols, key, false)
+}
+
+// packOf pack scanned cols to a model, cols are all nullable if nullable
+func (mm *ModelMapper) packOf(columns []string, cols []interface{}, key string, nullable bool) (model interface{}, id interface{}, err error) {
 	var fd *fieldDescriptor
 	var ok bool
 	var converter ValueConverter
@@ -182,7 +196,7 @@ func (mm *ModelMapper) pack(columns []string, cols []interface{}, key string) (m
 			}
 		}
 		var value reflect.Value
-		if fd.nullable {
+		if nullable || fd.nullable {
 			switch field.Interface().(type) {
 			case int:
 				t := col.(sql.NullInt64)
@@ -287,6 +301,8 @@ func (mm *ModelMapper) pack(columns []string, cols []interface{}, key string) (m
 				if t.Valid {
 					value = reflect.ValueOf(t.Time)
 				}
+			case NullTime:
+				value = reflect.ValueOf(col.(NullTime))
 			default:
 				err = &Error{
 					ERR_UNKNOWN_COLTYPE,
@@ -334,6 +350,42 @@ func (mm *ModelMapper) extract(model interface{}) (result map[string]interface{}
 	return
 }
 
+// trash set the soft delete col of model to at, a zero at means restore
+func (mm *ModelMapper) trash(model interface{}, at time.Time) error {
+	fd, ok := mm.fd(mm.deleted)
+	if !ok {
+		return &Error{ERR_COL_UNDEFINED, errors.New("soft delete col undefined")}
+	}
+	field := mm.modelValue(model).FieldByName(fd.fieldname)
+	switch field.Interface().(type) {
+	case time.Time:
+		field.Set(reflect.ValueOf(at))
+	case NullTime:
+		field.Set(reflect.ValueOf(NullTime{at, !at.IsZero()}))
+	default:
+		return &Error{
+			ERR_UNKNOWN_COLTYPE,
+			errors.New("soft delete col " + mm.deleted + " must be a time"),
+		}
+	}
+	return nil
+}
+
+// trashed tell if the model is soft deleted
+func (mm *ModelMapper) trashed(model interface{}) bool {
+	fd, ok := mm.fd(mm.deleted)
+	if !ok {
+		return false
+	}
+	switch at := mm.modelValue(model).FieldByName(fd.fieldname).Interface().(type) {
+	case time.Time:
+		return !at.IsZero()
+	case NullTime:
+		return at.Valid
+	}
+	return false
+}
+
 func (mm *ModelMapper) colValue(model interface{}, colname string) (result interface{}, err error) {
 	values := mm.modelValue(model)
 	if fd, ok := mm.fd(colname); ok {
@@ -49,3 +49,33 @@ func TestPack(t *T) {
 		t.Fatal(err)
 	}
 }
+
+type TestTrashedUser struct {
+	Id        string   `db:"id | varchar(128) | pk"`
+	DeletedAt NullTime `db:"deleted_at | datetime | nil,softdelete"`
+	*Base
+}
+
+func (u *TestTrashedUser) TableName() string {
+	return "users"
+}
+
+func TestPackTrashed(t *T) {
+	mm := NewModelMapper(New(new(TestTrashedUser)))
+	cols := []string{"id", "deleted_at"}
+	res, err := mm.cols(cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*res[0].(*string) = "1"
+	if err = res[1].(*NullTime).Scan(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := mm.pack(cols, res, "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.(*TestTrashedUser).DeletedAt.Valid || !mm.trashed(m) {
+		t.Fatal("pack trashed error")
+	}
+}
@@ -2,7 +2,9 @@ package model
 
 import (
 	"database/sql"
+	"errors"
 	. "github.com/yang-zzhong/go-querybuilder"
+	"time"
 )
 
 type rowshandler func(*sql.Rows, []string) error
@@ -13,9 +15,24 @@ type modify func(model interface{}) error
 type setpage func(*Repo) error
 
 const (
-	t_one  = 1 // relationship is a has one relationship
-	t_many = 2 // relationship is a has many relationship
-	t_bad  = 3 // bad or not found relationship
+	t_one          = 1 // relationship is a has one relationship
+	t_many         = 2 // relationship is a has many relationship
+	t_bad          = 3 // bad or not found relationship
+	t_pivot        = 4 // relationship is a belongs to many relationship through a pivot table
+	t_morph        = 5 // relationship is a morph to relationship
+	t_many_through = 6 // relationship is a has many relationship through another model
+	t_one_through  = 7 // relationship is a has one relationship through another model
+)
+
+// toMany tell if relationship type t finds many targets
+func toMany(t int) bool {
+	return t == t_many || t == t_pivot || t == t_many_through
+}
+
+const (
+	trashed_without = 0 // soft deleted rows excluded
+	trashed_with    = 1 // soft deleted rows included
+	trashed_only    = 2 // only soft deleted rows
 )
 
 // a relationship with repo
@@ -23,18 +40,33 @@ type with struct {
 	name    string      // relationship name
 	m       interface{} // relationship target
 	n       Nexus       // relationship nexus
-	t       int         // relationship type t_one|t_many
+	t       int         // relationship type like t_one|t_many
+	pivot   *pivot      // pivot of t_pivot relationship
+	morph   string      // col prefix of t_morph relationship
+	through *through    // through of t_many_through|t_one_through relationship
 	handler repoHandler
+	// modify the target repo before fetching, like loading nested nexus
+	constraints []func(*Repo)
 }
 
 // repo
 type Repo struct {
-	model    interface{} // repo row model
-	modifier Modifier    // sql modifier
-	oncreate modify      // on create callback
-	onupdate modify      // on update callback
-	ondelete modify      // on delete callback
-	withs    []with      // maintain fetch model relationship
+	model    interface{}   // repo row model
+	modifier Modifier      // sql modifier
+	oncreate modify        // on create callback
+	onupdate modify        // on update callback
+	ondelete modify        // on delete callback
+	withs    []with        // maintain fetch model relationship
+	joins    []join        // maintain joined has one relationship
+	joinArgs []interface{} // params of the joined tables
+	extras   []string      // prefixes of the cols set as extras
+	alias    string        // alias of the model table in sql, if aliased
+	aggs     []aggregate   // maintain fetch aggregates of relationship
+	trashed  int           // soft deleted rows scope
+	scoped   bool          // scope applied to builder
+	err      error         // error raised while building the query
+	lock     int           // row lock of query
+	lockOpt  int           // what to do with rows locked by others
 	*Builder
 }
 
@@ -43,26 +75,43 @@ func NewRepo(m interface{}, p Modifier) *Repo {
 	repo := new(Repo)
 	repo.model = m
 	repo.modifier = p
-	repo.oncreate = func(_ interface{}) error { return nil }
-	repo.onupdate = func(_ interface{}) error { return nil }
-	repo.ondelete = func(_ interface{}) error { return nil }
 	repo.Builder = NewBuilder(p)
 	repo.withs = []with{}
+	repo.joins = []join{}
+	repo.aggs = []aggregate{}
 	repo.From(repo.model.(Model).TableName())
 
 	return repo
 }
 
 func (repo *Repo) Another() *Repo {
+	return repo.another(repo.model.(Model).TableName())
+}
+
+// aliased new a repo like Another with the model table aliased as alias
+func (repo *Repo) aliased(alias string) *Repo {
+	r := repo.another(repo.model.(Model).TableName() + " AS " + quote(dialectOf(repo.modifier), alias))
+	r.alias = alias
+
+	return r
+}
+
+// another new a repo of the same model and settings finding from table
+func (repo *Repo) another(table string) *Repo {
 	r := new(Repo)
 	r.model = repo.model
 	r.modifier = repo.modifier
 	r.oncreate = repo.oncreate
 	r.onupdate = repo.onupdate
 	r.ondelete = repo.ondelete
+	r.trashed = repo.trashed
+	r.lock = repo.lock
+	r.lockOpt = repo.lockOpt
 	r.Builder = NewBuilder(r.modifier)
 	r.withs = []with{}
-	r.From(r.model.(Model).TableName())
+	r.joins = []join{}
+	r.aggs = []aggregate{}
+	r.From(table)
 
 	return r
 }
@@ -88,11 +137,64 @@ func (repo *Repo) OnDelete(c modify) *Repo {
 // clean builder
 func (repo *Repo) Clean() {
 	repo.Builder.Init()
+	repo.scoped = false
+	repo.err = nil
+}
+
+// WithTrashed tell repo to include soft deleted rows
+func (repo *Repo) WithTrashed() *Repo {
+	repo.trashed = trashed_with
+	return repo
+}
+
+// OnlyTrashed tell repo to find soft deleted rows only
+func (repo *Repo) OnlyTrashed() *Repo {
+	repo.trashed = trashed_only
+	return repo
+}
+
+// prepare apply the soft delete condition and joins to builder once before
+// query, and report the error raised while building the query
+func (repo *Repo) prepare() error {
+	if repo.err != nil || repo.scoped {
+		return repo.err
+	}
+	repo.scoped = true
+	repo.scope()
+	repo.err = repo.join()
+
+	return repo.err
+}
+
+// table find the name of the model table in sql, the alias if aliased
+func (repo *Repo) table() string {
+	if repo.alias != "" {
+		return repo.alias
+	}
+	return repo.model.(Model).TableName()
+}
+
+// scope apply the soft delete condition to builder
+func (repo *Repo) scope() {
+	col := repo.model.(Mapable).Mapper().deleted
+	if col == "" {
+		return
+	}
+	col = repo.table() + "." + col
+	switch repo.trashed {
+	case trashed_without:
+		repo.WhereRaw(col + " IS NULL")
+	case trashed_only:
+		repo.WhereRaw(col + " IS NOT NULL")
+	}
 }
 
 func (repo *Repo) Count() (int, error) {
+	if err := repo.prepare(); err != nil {
+		return 0, err
+	}
 	db := repo.model.(Model).DB()
-	rows, err := db.Query(repo.ForCount(), repo.Params()...)
+	rows, err := db.Query(repo.ForCount(), repo.params()...)
 	if err != nil {
 		return 0, err
 	}
@@ -115,8 +217,40 @@ func (repo *Repo) MustCount() int {
 }
 
 func (repo *Repo) Query(handle rowshandler) error {
+	if err := repo.prepare(); err != nil {
+		return err
+	}
+	if repo.lock != lock_none && repo.model.(Model).DB().tx() == nil {
+		return &Error{ERR_NOT_IN_TX, errors.New("lock rows outside a tx")}
+	}
+	return repo.query(repo.forQuery(), repo.params(), handle)
+}
+
+// matchedQuery generate the query selecting the pks of the rows matched by
+// repo, with the scope and joins applied by prepare, which is applied once
+// however many times it's called. the select of repo is left as it is, the
+// pks are selected from it as a derived table
+func (repo *Repo) matchedQuery() (string, []interface{}, error) {
+	if err := repo.prepare(); err != nil {
+		return "", nil, err
+	}
+	pk := repo.model.(Model).PK()
+	return "SELECT matched." + pk + " FROM (" + repo.ForQuery() + ") AS matched", repo.params(), nil
+}
+
+// params put params of the joined tables and params of builder together
+func (repo *Repo) params() []interface{} {
+	return bindArgs(dialectOf(repo.modifier), repo.joinArgs, repo.Params())
+}
+
+// forQuery generate the query sql with the row lock
+func (repo *Repo) forQuery() string {
+	return repo.ForQuery() + lockClause(dialectOf(repo.modifier), repo.lock, repo.lockOpt)
+}
+
+func (repo *Repo) query(sqlang string, params []interface{}, handle rowshandler) error {
 	db := repo.model.(Model).DB()
-	rows, err := db.Query(repo.ForQuery(), repo.Params()...)
+	rows, err := db.Query(sqlang, params...)
 	if err != nil {
 		return err
 	}
@@ -134,6 +268,28 @@ func (repo *Repo) Query(handle rowshandler) error {
 	return nil
 }
 
+func (repo *Repo) MustRaw(sqlang string, params ...interface{}) []interface{} {
+	if ms, err := repo.Raw(sqlang, params...); err != nil {
+		panic(err)
+	} else {
+		return ms
+	}
+}
+
+// Raw fetch models with a raw sql, each selected col must be defined on model
+func (repo *Repo) Raw(sqlang string, params ...interface{}) (models []interface{}, err error) {
+	err = repo.query(sqlang, params, repo.packer(func(m interface{}, _ interface{}) error {
+		models = append(models, m)
+		return nil
+	}))
+	if err != nil {
+		return
+	}
+	err = repo.loadNexus(models)
+
+	return
+}
+
 func (repo *Repo) MustFetch() []interface{} {
 	if ms, err := repo.Fetch(); err != nil {
 		panic(err)
@@ -150,13 +306,7 @@ func (repo *Repo) Fetch() (models []interface{}, err error) {
 	if err != nil {
 		return
 	}
-	if nexusValues, rerr := repo.nexusValues(models); rerr == nil {
-		for id, _ := range models {
-			repo.bindNexus(models[id], nexusValues)
-		}
-	} else {
-		err = rerr
-	}
+	err = repo.loadNexus(models)
 
 	return
 }
@@ -180,38 +330,62 @@ func (repo *Repo) FetchKey(col string) (models map[interface{}]interface{}, err
 	if err != nil {
 		return
 	}
-	if nexusValues, rerr := repo.nexusValues(forNexusValues); rerr == nil {
-		for id, _ := range models {
-			repo.bindNexus(models[id], nexusValues)
-		}
-	} else {
-		err = rerr
-	}
+	err = repo.loadNexus(forNexusValues)
 
 	return
 }
 
+// loadNexus fetch the nexus declared by With and bind them to models
+func (repo *Repo) loadNexus(models []interface{}) error {
+	nexusValues, err := repo.nexusValues(models)
+	if err != nil {
+		return err
+	}
+	for id, _ := range models {
+		repo.bindNexus(models[id], nexusValues)
+	}
+	return repo.bindAggregates(models)
+}
+
 func (repo *Repo) fetch(handle handlerForQueryModel) error {
-	var cols []interface{}
-	colget := false
-	return repo.Query(func(rows *sql.Rows, columns []string) error {
+	return repo.Query(repo.packer(handle))
+}
+
+// packer make a rows handler which pack each row to a model
+func (repo *Repo) packer(handle handlerForQueryModel) rowshandler {
+	var pointers []interface{}
+	var parts []*part
+	return func(rows *sql.Rows, columns []string) error {
 		var err error
-		if !colget {
-			if cols, err = repo.model.(Mapable).Mapper().cols(columns); err != nil {
+		if pointers == nil {
+			if pointers, parts, err = repo.parts(columns); err != nil {
 				return err
 			}
-			colget = true
 		}
-		if err = rows.Scan(cols...); err != nil {
+		if err = rows.Scan(pointers...); err != nil {
 			return &Error{ERR_SCAN, err}
 		}
 		var m, id interface{}
-		m, id, err = repo.model.(Mapable).Mapper().pack(columns, cols, repo.model.(Model).PK())
-		if err == nil {
-			return handle(m, id)
+		m, id, err = parts[0].mapper.pack(parts[0].columns, parts[0].cols, repo.model.(Model).PK())
+		if err != nil {
+			return err
 		}
-		return err
-	})
+		for _, p := range parts[1:] {
+			if p.mapper == nil {
+				p.extra(m)
+				continue
+			}
+			var one interface{}
+			if one, err = p.pack(); err != nil {
+				return err
+			}
+			m.(NexusOne).SetOne(p.name, one)
+		}
+		if err = fire(m, AFTER_FETCH); err != nil {
+			return err
+		}
+		return handle(m, id)
+	}
 }
 
 func (repo *Repo) MustOne() interface{} {
@@ -257,15 +431,63 @@ func (repo *Repo) Find(id interface{}) (interface{}, bool, error) {
 	return nil, false, nil
 }
 
+// UpdateRaw update the rows matched by repo with raw, the matched rows are
+// fetched for the update hooks if models have any
 func (repo *Repo) UpdateRaw(raw map[string]interface{}) error {
 	db := repo.model.(Model).DB()
-	_, err := db.Exec(repo.ForUpdate(raw), repo.Params()...)
-	return err
+	if !repo.hooked(append(before_update, after_update...)...) {
+		r, err := repo.matchedRepo()
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(r.ForUpdate(raw), r.params()...)
+		return err
+	}
+	return repo.writeMatched(before_update, after_update, true, func(r *Repo) error {
+		_, err := db.Exec(r.ForUpdate(raw), r.params()...)
+		return err
+	})
 }
 
+// DeleteRaw soft delete the rows matched by repo if model has a softdelete
+// col, otherwise remove them. the matched rows are fetched for the delete
+// hooks if models have any
 func (repo *Repo) DeleteRaw(raw map[string]interface{}) error {
+	if !repo.hooked(append(before_delete, after_delete...)...) {
+		r, err := repo.matchedRepo()
+		if err != nil {
+			return err
+		}
+		return r.deleteRaw()
+	}
+	return repo.writeMatched(before_delete, after_delete, false, func(r *Repo) error {
+		return r.deleteRaw()
+	})
+}
+
+// matchedRepo new a repo on the rows matched by repo, so that an update or
+// a remove sees the same rows as a fetch does
+func (repo *Repo) matchedRepo() (*Repo, error) {
+	query, params, err := repo.matchedQuery()
+	if err != nil {
+		return nil, err
+	}
+	pk := repo.model.(Model).TableName() + "." + repo.model.(Model).PK()
+	r := repo.Another().WithTrashed()
+	r.WhereRaw(pk+" IN ("+unbind(dialectOf(repo.modifier), query)+")", params...)
+
+	return r, nil
+}
+
+// deleteRaw soft delete or remove the rows of repo
+func (repo *Repo) deleteRaw() error {
 	db := repo.model.(Model).DB()
-	_, err := db.Exec(repo.ForRemove(), repo.Params()...)
+	sqlang := repo.ForRemove()
+	if col := repo.model.(Mapable).Mapper().deleted; col != "" {
+		sqlang = repo.ForUpdate(map[string]interface{}{col: time.Now()})
+	}
+	_, err := db.Exec(sqlang, repo.params()...)
+
 	return err
 }
 
@@ -275,80 +497,133 @@ func (repo *Repo) Update(model interface{}) error {
 	if err != nil {
 		return err
 	}
-	if err := repo.onupdate(model); err != nil {
+	return repo.write([]interface{}{model}, before_update, after_update, func() error {
+		r := repo.Another()
+		sql := r.Where(field, v).ForUpdate(repo.model.(Mapable).Mapper().extract(model))
+		db := repo.model.(Model).DB()
+		_, err := db.Exec(sql, r.Params()...)
 		return err
-	}
-	r := repo.Another()
-	sql := r.Where(field, v).ForUpdate(repo.model.(Mapable).Mapper().extract(model))
-	db := repo.model.(Model).DB()
-	_, err = db.Exec(sql, r.Params()...)
-
-	return err
+	})
 }
 
 func (repo *Repo) Creates(models []interface{}) error {
-	r := repo.Another()
-	var data []map[string]interface{}
-	for _, m := range models {
-		if err := r.oncreate(m); err != nil {
+	return repo.write(models, before_create, after_create, func() error {
+		r := repo.Another()
+		var data []map[string]interface{}
+		for _, m := range models {
+			data = append(data, r.model.(Mapable).Mapper().extract(m))
+		}
+		db := repo.model.(Model).DB()
+		if _, err := db.Exec(r.ForInsert(data), r.Params()...); err != nil {
 			return err
 		}
-		data = append(data, r.model.(Mapable).Mapper().extract(m))
-	}
-	db := repo.model.(Model).DB()
-	if _, err := db.Exec(r.ForInsert(data), r.Params()...); err != nil {
-		return err
-	}
-	for _, m := range models {
-		m.(Model).SetFresh(false)
-	}
-
-	return nil
+		for _, m := range models {
+			m.(Model).SetFresh(false)
+		}
+		return nil
+	})
 }
 
 func (repo *Repo) Create(model interface{}) error {
-	var data []map[string]interface{}
-	if err := repo.oncreate(model); err != nil {
-		return err
-	}
-	data = append(data, repo.model.(Mapable).Mapper().extract(model))
-	r := repo.Another()
-	db := repo.model.(Model).DB()
-	if _, err := db.Exec(r.ForInsert(data), r.Params()...); err != nil {
-		return err
-	}
-	model.(Model).SetFresh(false)
-
-	return nil
+	return repo.write([]interface{}{model}, before_create, after_create, func() error {
+		var data []map[string]interface{}
+		data = append(data, repo.model.(Mapable).Mapper().extract(model))
+		r := repo.Another()
+		db := repo.model.(Model).DB()
+		if _, err := db.Exec(r.ForInsert(data), r.Params()...); err != nil {
+			return err
+		}
+		model.(Model).SetFresh(false)
+		return nil
+	})
 }
 
+// Delete soft delete the model if it has a softdelete col, otherwise remove it
 func (repo *Repo) Delete(model interface{}) error {
-	if err := repo.ondelete(model); err != nil {
-		return err
+	return repo.Deletes([]interface{}{model})
+}
+
+// ForceDelete remove the model from db even if it has a softdelete col
+func (repo *Repo) ForceDelete(model interface{}) error {
+	return repo.ForceDeletes([]interface{}{model})
+}
+
+// Restore undo the soft delete of the model
+func (repo *Repo) Restore(model interface{}) error {
+	if repo.model.(Mapable).Mapper().deleted == "" {
+		return &Error{ERR_COL_UNDEFINED, errors.New("model has no softdelete col")}
 	}
+	models := []interface{}{model}
+	return repo.write(models, before_update, after_update, func() error {
+		return repo.trash(models, time.Time{})
+	})
+}
+
+// Deletes soft delete models if they have a softdelete col, otherwise remove them
+func (repo *Repo) Deletes(models []interface{}) error {
+	return repo.write(models, before_delete, after_delete, func() error {
+		return repo.cascade(models, false, func() error {
+			if repo.model.(Mapable).Mapper().deleted != "" {
+				return repo.trash(models, time.Now())
+			}
+			return repo.remove(models)
+		})
+	})
+}
+
+// ForceDeletes remove models from db even if they have a softdelete col
+func (repo *Repo) ForceDeletes(models []interface{}) error {
+	return repo.write(models, before_delete, after_delete, func() error {
+		return repo.cascade(models, true, func() error {
+			return repo.remove(models)
+		})
+	})
+}
+
+func (repo *Repo) pks(models []interface{}) (ids []interface{}, err error) {
 	field := repo.model.(Model).PK()
-	v, err := repo.model.(Mapable).Mapper().colValue(model, field)
+	for _, model := range models {
+		var v interface{}
+		if v, err = repo.model.(Mapable).Mapper().colValue(model, field); err != nil {
+			return
+		}
+		ids = append(ids, v)
+	}
+	return
+}
+
+func (repo *Repo) remove(models []interface{}) error {
+	ids, err := repo.pks(models)
 	if err != nil {
 		return err
 	}
 	r := repo.Another()
 	db := repo.model.(Model).DB()
-	_, err = db.Exec(r.Where(field, v).ForRemove(), r.Params()...)
+	_, err = db.Exec(r.WhereIn(repo.model.(Model).PK(), ids).ForRemove(), r.Params()...)
 	return err
 }
 
-func (repo *Repo) Deletes(models []interface{}) error {
-	field := repo.model.(Model).PK()
-	ids := []interface{}{}
-	for _, model := range models {
-		v, err := repo.model.(Mapable).Mapper().colValue(model, field)
-		if err != nil {
-			return err
-		}
-		ids = append(ids, v)
+// trash set the softdelete col of models to at, a zero at means restore
+func (repo *Repo) trash(models []interface{}, at time.Time) error {
+	ids, err := repo.pks(models)
+	if err != nil {
+		return err
+	}
+	mapper := repo.model.(Mapable).Mapper()
+	var value interface{}
+	if !at.IsZero() {
+		value = at
 	}
 	r := repo.Another()
+	r.WhereIn(repo.model.(Model).PK(), ids)
 	db := repo.model.(Model).DB()
-	_, err := db.Exec(r.WhereIn(field, ids).ForRemove(), r.Params()...)
-	return err
+	if _, err = db.Exec(r.ForUpdate(map[string]interface{}{mapper.deleted: value}), r.Params()...); err != nil {
+		return err
+	}
+	for _, m := range models {
+		if err = mapper.trash(m, at); err != nil {
+			return err
+		}
+	}
+	return nil
 }
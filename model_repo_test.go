@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	. "github.com/yang-zzhong/go-querybuilder"
 	"log"
+	"strings"
 	. "testing"
 	"time"
 )
@@ -38,6 +40,13 @@ func (u *User) Prepare() {
 		"user_id": "id",
 		"id":      NWhere{GT, 0},
 	})
+	u.DeclareInverse("books", "author")
+	u.DeclareMorphMany("comments", new(Comment), "commentable")
+	u.DeclareManyThrough("book_comments", new(Comment), new(Book),
+		Nexus{"user_id": "id"},
+		Nexus{"commentable_id": "id", "commentable_type": NWhere{"=", "book"}})
+	u.DeclareBelongsToMany("reading", new(Book), "book_reader",
+		Nexus{"user_id": "id"}, Nexus{"book_id": "id"})
 }
 
 func NewUser() *User {
@@ -45,9 +54,10 @@ func NewUser() *User {
 }
 
 type Book struct {
-	Id     string `db:"id | varchar(128) | pk"`
-	UserId string `db:"user_id | varchar(128)"`
-	Name   string `db:"name | varchar(128)"`
+	Id        string    `db:"id | varchar(128) | pk"`
+	UserId    string    `db:"user_id | varchar(128)"`
+	Name      string    `db:"name | varchar(128)"`
+	DeletedAt time.Time `db:"deleted_at | datetime | nil,softdelete"`
 	*Base
 }
 
@@ -65,6 +75,83 @@ func NewBook() *Book {
 	return New(new(Book)).(*Book)
 }
 
+type BookReader struct {
+	UserId string `db:"user_id | varchar(128)"`
+	BookId string `db:"book_id | varchar(128)"`
+	Note   string `db:"note | varchar(128) | nil"`
+	*Base
+}
+
+func (br *BookReader) TableName() string {
+	return "book_reader"
+}
+
+type Comment struct {
+	Id              string `db:"id | varchar(128) | pk"`
+	CommentableId   string `db:"commentable_id | varchar(128)"`
+	CommentableType string `db:"commentable_type | varchar(32)"`
+	Body            string `db:"body | varchar(256)"`
+	*Base
+}
+
+func (c *Comment) TableName() string {
+	return "comment"
+}
+
+func (c *Comment) Prepare() {
+	c.DeclareMorphTo("commentable", "commentable")
+}
+
+type Category struct {
+	Id       string `db:"id | varchar(128) | pk"`
+	ParentId string `db:"parent_id | varchar(128) | nil"`
+	Name     string `db:"name | varchar(32)"`
+	*Base
+}
+
+func (c *Category) TableName() string {
+	return "category"
+}
+
+func (c *Category) Prepare() {
+	c.DeclareTree("parent_id")
+}
+
+// a user with hooks counted in events
+type HookedUser struct {
+	Id       string `db:"id | varchar(128) | pk"`
+	Name     string `db:"name | varchar(32) | uk"`
+	Age      int    `db:"age | int"`
+	Level    int    `db:"level | int"`
+	Optional string `db:"optional | varchar(256) | nil"`
+	events   map[string]int
+	*Base
+}
+
+func (u *HookedUser) TableName() string {
+	return "user"
+}
+
+func (u *HookedUser) BeforeCreate() error {
+	u.Optional = "created"
+	return nil
+}
+
+func (u *HookedUser) AfterFetch() error {
+	if u.events == nil {
+		u.events = make(map[string]int)
+	}
+	u.events["fetch"]++
+	return nil
+}
+
+func (u *HookedUser) BeforeDelete() error {
+	if u.Level > 1 {
+		return errors.New("can't delete a user above level 1")
+	}
+	return nil
+}
+
 type withCustomCount struct {
 	data []map[string]interface{}
 }
@@ -100,6 +187,68 @@ func TestIsModelErr(t *T) {
 	}
 }
 
+func TestIsUniqueViolation(t *T) {
+	if !IsUniqueViolation(errors.New("Error 1062: Duplicate entry '1' for key 'PRIMARY'")) {
+		t.Fatal("is unique violation error")
+	}
+	if IsUniqueViolation(errors.New("Error 1146: Table 'test_go.user' doesn't exist")) {
+		t.Fatal("is unique violation error")
+	}
+}
+
+func TestFirstOrCreate(t *T) {
+	suit(func(t *T) error {
+		repo := NewUser().Repo()
+		attrs := map[string]interface{}{"id": "1"}
+		defaults := map[string]interface{}{"name": "yang-zhong", "age": 17, "level": 1}
+		created, err := repo.FirstOrCreate(attrs, defaults)
+		if err != nil {
+			return err
+		}
+		if !isUser(created) {
+			return errors.New("first or create error when create")
+		}
+		found, err := repo.FirstOrCreate(attrs, map[string]interface{}{"name": "other"})
+		if err != nil {
+			return err
+		}
+		if !isUser(found) || repo.Another().MustCount() != 1 {
+			return errors.New("first or create error when found")
+		}
+		err = repo.model.(Model).DB().Tx(func(_ *sql.Tx) error {
+			_, err := repo.Another().FirstOrCreate(map[string]interface{}{"id": "2"}, defaults)
+			return err
+		})
+		if err != nil || repo.Another().MustCount() != 2 {
+			return errors.New("first or create error in tx")
+		}
+		return nil
+	}, t, "first or create")
+}
+
+func TestUpdateOrCreate(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		repo := user.Repo()
+		m, err := repo.UpdateOrCreate(map[string]interface{}{"id": "1"}, map[string]interface{}{"age": 18})
+		if err != nil {
+			return err
+		}
+		if m.(*User).Age != 18 || repo.Another().MustCount() != 1 {
+			return errors.New("update or create error")
+		}
+		n, err := repo.FirstOrNew(map[string]interface{}{"id": "2"}, map[string]interface{}{"name": "new"})
+		if err != nil {
+			return err
+		}
+		if !n.(*User).IsFresh() || n.(*User).Name != "new" {
+			return errors.New("first or new error")
+		}
+		return nil
+	}, t, "update or create")
+}
+
 func TestCreate(t *T) {
 	suit(func(t *T) error {
 		user := NewUser()
@@ -179,6 +328,32 @@ func TestCreates(t *T) {
 	}, t, "create slice")
 }
 
+func TestUpsert(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		another := NewUser()
+		another.Id = "1"
+		another.Name = "fixed name"
+		another.Age = 18
+		repo := user.Repo()
+		if err := repo.UpsertExcept([]interface{}{another}, []string{"id"}, []string{"created_at"}); err != nil {
+			return err
+		}
+		if u := repo.MustFind("1"); u.(*User).Name != "fixed name" || u.(*User).Age != 18 {
+			return errors.New("upsert error")
+		}
+		another.Name = "ignored"
+		if err := repo.CreateOrIgnore([]interface{}{another}, []string{"id"}); err != nil {
+			return err
+		}
+		if u := repo.MustFind("1"); u.(*User).Name != "fixed name" {
+			return errors.New("create or ignore error")
+		}
+		return nil
+	}, t, "upsert")
+}
+
 func TestUpdate(t *T) {
 	suit(func(t *T) error {
 		var err error
@@ -201,6 +376,40 @@ func TestUpdate(t *T) {
 	}, t, "update")
 }
 
+func TestIncrement(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		if err := user.Increment("age", 2); err != nil {
+			return err
+		}
+		if user.Age != 19 {
+			return errors.New("increment error")
+		}
+		repo := user.Repo().Another()
+		repo.Where("id", "1")
+		if err := repo.Decrement("age", 1); err != nil {
+			return err
+		}
+		if u := user.Repo().MustFind("1"); u.(*User).Age != 18 {
+			return errors.New("decrement error")
+		}
+		if ms := repo.MustFetch(); len(ms) != 1 || ms[0].(*User).Name != user.Name {
+			return errors.New("decrement touched the repo select error")
+		}
+		err := user.UpdateExpr(map[string]interface{}{
+			"level": NewExpr("level * ? + ?", 3, 1),
+		})
+		if err != nil {
+			return err
+		}
+		if user.Level != 4 {
+			return errors.New("update expr error")
+		}
+		return nil
+	}, t, "increment")
+}
+
 func TestCount(t *T) {
 	suit(func(t *T) error {
 		user := NewUser()
@@ -228,7 +437,7 @@ func TestFetchNexus(t *T) {
 				if many, err = user.(*User).Many("books"); err != nil {
 					return err
 				}
-				for _, m := range many.(map[interface{}]interface{}) {
+				for _, m := range many.(Collection) {
 					if !isBook(m) {
 						return err
 					}
@@ -252,6 +461,67 @@ func TestFetchNexus(t *T) {
 	}, t, "fetch nexus")
 }
 
+func TestNestedWith(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		users, err := NewUser().Repo().With("books.author").Fetch()
+		if err != nil {
+			return err
+		}
+		for _, m := range users {
+			many, _ := m.(*User).Many("books")
+			if len(many.(Collection)) != 1 {
+				return errors.New("nested with error")
+			}
+			for _, b := range many.(Collection) {
+				if !b.(*Book).Loaded("author") {
+					return errors.New("nested with not loaded error")
+				}
+				if author, _ := b.(*Book).One("author"); !isUser(author) {
+					return errors.New("nested with error")
+				}
+			}
+		}
+		users = NewUser().Repo().With("books", func(r *Repo) {
+			r.Where("name", "no such book")
+		}).MustFetch()
+		for _, m := range users {
+			if many, _ := m.(*User).Many("books"); len(many.(Collection)) != 0 {
+				return errors.New("constrained with error")
+			}
+		}
+		return nil
+	}, t, "nested with")
+}
+
+func TestWhereHas(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		if count := NewUser().Repo().WhereHas("books", nil).MustCount(); count != 1 {
+			return errors.New("where has error")
+		}
+		count := NewUser().Repo().WhereHas("books", func(r *Repo) {
+			r.Where("name", "no such book")
+		}).MustCount()
+		if count != 0 {
+			return errors.New("where has with conditions error")
+		}
+		if count := NewUser().Repo().WhereDoesntHave("books", nil).MustCount(); count != 0 {
+			return errors.New("where doesn't have error")
+		}
+		if _, err := NewUser().Repo().WhereHas("undefined", nil).Count(); err == nil {
+			return errors.New("where has undefined nexus error")
+		}
+		return nil
+	}, t, "where has")
+}
+
 func TestWithMany(t *T) {
 	suit(func(t *T) error {
 		user := NewUser()
@@ -261,7 +531,7 @@ func TestWithMany(t *T) {
 		if many, err := user.Many("books"); err != nil {
 			return err
 		} else {
-			for _, m := range many.(map[interface{}]interface{}) {
+			for _, m := range many.(Collection) {
 				if !isBook(m) {
 					return err
 				}
@@ -271,6 +541,547 @@ func TestWithMany(t *T) {
 	}, t, "with many")
 }
 
+func TestBelongsToMany(t *T) {
+	suit(func(t *T) error {
+		pr := New(new(BookReader)).(Model).Repo()
+		if err := pr.CreateRepo(); err != nil {
+			return err
+		}
+		defer clearRepo(pr)
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		ids := []interface{}{book.Id}
+		if err := user.Attach("reading", ids, map[string]interface{}{"note": "chapter 1"}); err != nil {
+			return err
+		}
+		users := NewUser().Repo().With("reading").MustFetch()
+		for _, m := range users {
+			many, _ := m.(*User).Many("reading")
+			if len(many.(Collection)) != 1 {
+				return errors.New("with belongs to many error")
+			}
+			for _, b := range many.(Collection) {
+				if !isBook(b) || b.(*Book).Pivot()["note"] == nil {
+					return errors.New("belongs to many pivot error")
+				}
+			}
+		}
+		if count := NewUser().Repo().WhereHas("reading", nil).MustCount(); count != 1 {
+			return errors.New("where has belongs to many error")
+		}
+		if err := user.Toggle("reading", ids); err != nil {
+			return err
+		}
+		if count := NewUser().Repo().WhereHas("reading", nil).MustCount(); count != 0 {
+			return errors.New("toggle belongs to many error")
+		}
+		if err := user.Sync("reading", ids); err != nil {
+			return err
+		}
+		if err := user.Detach("reading", nil); err != nil {
+			return err
+		}
+		if many, _ := user.Many("reading"); len(many.(Collection)) != 0 {
+			return errors.New("detach belongs to many error")
+		}
+		return nil
+	}, t, "belongs to many")
+}
+
+func TestMorph(t *T) {
+	suit(func(t *T) error {
+		RegisterMorph("user", new(User))
+		RegisterMorph("book", new(Book))
+		cr := New(new(Comment)).(Model).Repo()
+		if err := cr.CreateRepo(); err != nil {
+			return err
+		}
+		defer clearRepo(cr)
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		for i, typ := range []string{"user", "book"} {
+			comment := New(&Comment{
+				Id:              fmt.Sprint(i + 1),
+				CommentableId:   "1",
+				CommentableType: typ,
+				Body:            "nice",
+			}).(*Comment)
+			if err := comment.Create(); err != nil {
+				return err
+			}
+		}
+		users := NewUser().Repo().With("comments").MustFetch()
+		for _, m := range users {
+			if many, _ := m.(*User).Many("comments"); len(many.(Collection)) != 1 {
+				return errors.New("with morph many error")
+			}
+		}
+		orphan := New(&Comment{Id: "3", CommentableId: "1", CommentableType: "unknown", Body: "lost"}).(*Comment)
+		if err := orphan.Create(); err != nil {
+			return err
+		}
+		comments := New(new(Comment)).(Model).Repo().With("commentable").MustFetch()
+		if len(comments) != 3 {
+			return errors.New("fetch comments error")
+		}
+		for _, m := range comments {
+			one, _ := m.(*Comment).One("commentable")
+			if m.(*Comment).CommentableType == "user" && !isUser(one) ||
+				m.(*Comment).CommentableType == "book" && !isBook(one) ||
+				m.(*Comment).CommentableType == "unknown" && one != nil {
+				return errors.New("with morph to error")
+			}
+		}
+		comment := New(new(Comment)).(Model)
+		comment.Repo().Where("id", "2")
+		m, _, err := comment.Repo().One()
+		if err != nil {
+			return err
+		}
+		if one, _ := m.(*Comment).One("commentable"); !isBook(one) {
+			return errors.New("lazy morph to error")
+		}
+		return nil
+	}, t, "morph")
+}
+
+func TestManyThrough(t *T) {
+	suit(func(t *T) error {
+		cr := New(new(Comment)).(Model).Repo()
+		if err := cr.CreateRepo(); err != nil {
+			return err
+		}
+		defer clearRepo(cr)
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		for i, typ := range []string{"user", "book"} {
+			comment := New(&Comment{
+				Id:              fmt.Sprint(i + 1),
+				CommentableId:   "1",
+				CommentableType: typ,
+				Body:            "nice",
+			}).(*Comment)
+			if err := comment.Create(); err != nil {
+				return err
+			}
+		}
+		users := NewUser().Repo().With("book_comments").MustFetch()
+		for _, m := range users {
+			many, _ := m.(*User).Many("book_comments")
+			if len(many.(Collection)) != 1 {
+				return errors.New("with many through error")
+			}
+			for _, c := range many.(Collection) {
+				if c.(*Comment).CommentableType != "book" {
+					return errors.New("with many through error")
+				}
+				if _, ok := c.(*Comment).Map()["through__user_id"]; ok {
+					return errors.New("through extras leak to map error")
+				}
+			}
+		}
+		many, err := user.Many("book_comments")
+		if err != nil {
+			return err
+		}
+		if len(many.(Collection)) != 1 {
+			return errors.New("lazy many through error")
+		}
+		return nil
+	}, t, "many through")
+}
+
+func TestPush(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		user.Id = "1"
+		user.Name = "yang-zhong"
+		user.Age = 17
+		user.Level = 1
+		book := NewBook()
+		book.Id = "1"
+		book.Name = "hello world"
+		user.SetMany("books", Collection{book})
+		if err := user.Push(); err != nil {
+			return err
+		}
+		m, ok, err := NewBook().Repo().Find("1")
+		if err != nil {
+			return err
+		}
+		if !ok || m.(*Book).UserId != "1" {
+			return errors.New("push has many error")
+		}
+		other := NewBook()
+		other.Id = "2"
+		other.Name = "hello world"
+		author := NewUser()
+		author.Id = "2"
+		author.Name = "yang"
+		other.SetOne("author", author)
+		if err := other.Push(); err != nil {
+			return err
+		}
+		if other.UserId != "2" || NewUser().Repo().MustCount() != 2 {
+			return errors.New("push has one error")
+		}
+		pr := New(new(BookReader)).(Model).Repo()
+		if err := pr.CreateRepo(); err != nil {
+			return err
+		}
+		defer clearRepo(pr)
+		user.SetMany("reading", Collection{book, other})
+		if err := user.Push(); err != nil {
+			return err
+		}
+		if err := user.Push(); err != nil {
+			return err
+		}
+		if count := New(new(BookReader)).(Model).Repo().MustCount(); count != 2 {
+			return errors.New("push belongs to many error")
+		}
+		return nil
+	}, t, "push")
+}
+
+func TestDeletePolicy(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		user.DeclareOnDelete("books", ON_DELETE_RESTRICT)
+		err := user.Delete()
+		if e, ok := err.(*Error); !ok || e.Code != ERR_DELETE_RESTRICTED {
+			return errors.New("delete restrict error")
+		}
+		book.DeclareOnDelete("author", ON_DELETE_CASCADE)
+		if err = book.Delete(); err == nil || NewUser().Repo().MustCount() != 1 {
+			return errors.New("cascade to referred nexus error")
+		}
+		book.DeclareOnDelete("author", ON_DELETE_NONE)
+		user.DeclareOnDelete("books", ON_DELETE_CASCADE)
+		if err = user.Delete(); err != nil {
+			return err
+		}
+		if NewBook().Repo().MustCount() != 0 || NewBook().Repo().OnlyTrashed().MustCount() != 1 {
+			return errors.New("delete cascade error")
+		}
+		user = NewUser()
+		insertUser(user)
+		user.DeclareOnDelete("books", ON_DELETE_CASCADE)
+		if err = user.ForceDelete(); err != nil {
+			return err
+		}
+		if NewBook().Repo().WithTrashed().MustCount() != 0 {
+			return errors.New("force delete cascade error")
+		}
+		return nil
+	}, t, "delete policy")
+}
+
+func TestCollectionLoad(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		users, err := NewUser().Repo().Collect()
+		if err != nil {
+			return err
+		}
+		if err = users.Load("books.author"); err != nil {
+			return err
+		}
+		for _, m := range users {
+			if !m.(*User).Loaded("books") {
+				return errors.New("collection load not loaded error")
+			}
+			many, _ := m.(*User).Many("books")
+			if len(many.(Collection)) != 1 {
+				return errors.New("collection load error")
+			}
+			for _, b := range many.(Collection) {
+				if !b.(*Book).Loaded("author") {
+					return errors.New("collection load nested error")
+				}
+			}
+		}
+		return nil
+	}, t, "collection load")
+}
+
+func TestOrderedWith(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		for i, name := range []string{"b", "c", "a"} {
+			book := NewBook()
+			book.Id = fmt.Sprint(i + 1)
+			book.UserId = user.Id
+			book.Name = name
+			if err := book.Create(); err != nil {
+				return err
+			}
+		}
+		repo := NewBook().Repo()
+		repo.OrderBy("name", DESC)
+		books := repo.MustCollect()
+		if names := books.Pluck("name"); len(names) != 3 || names[0] != "c" || names[2] != "a" {
+			return errors.New("ordered collect error")
+		}
+		users := NewUser().Repo().With("books", func(r *Repo) {
+			r.OrderBy("name", ASC)
+		}).MustCollect()
+		for _, m := range users {
+			many, _ := m.(*User).Many("books")
+			if names := many.(Collection).Pluck("name"); len(names) != 3 || names[0] != "a" || names[2] != "c" {
+				return errors.New("ordered with error")
+			}
+		}
+		return nil
+	}, t, "ordered with")
+}
+
+func TestReload(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		if many, _ := user.Many("books"); len(many.(Collection)) != 0 || !user.Loaded("books") {
+			return errors.New("load many error")
+		}
+		book := NewBook()
+		book.Id = "1"
+		book.Name = "hello world"
+		if err := user.SaveRelated("books", book); err != nil {
+			return err
+		}
+		if user.Loaded("books") || book.UserId != user.Id {
+			return errors.New("save related error")
+		}
+		user.SetMany("books", Collection{})
+		if many, err := user.Reload("books"); err != nil || len(many.(Collection)) != 1 {
+			return errors.New("reload many error")
+		}
+		user.Age = 0
+		user.SetExtra("books_count", 1)
+		user.SetPivot(map[string]interface{}{"note": "stale"})
+		if err := user.Refresh(); err != nil {
+			return err
+		}
+		if user.Age != 17 || user.Loaded("books") || user.Extra("books_count") != nil || user.Pivot() != nil {
+			return errors.New("refresh error")
+		}
+		return nil
+	}, t, "reload")
+}
+
+func TestTree(t *T) {
+	suit(func(t *T) error {
+		cr := New(new(Category)).(Model).Repo()
+		if err := cr.CreateRepo(); err != nil {
+			return err
+		}
+		defer clearRepo(cr)
+		categories := []*Category{}
+		for i, name := range []string{"root", "branch", "leaf"} {
+			c := New(&Category{Id: fmt.Sprint(i + 1), Name: name}).(*Category)
+			if i > 0 {
+				c.ParentId = fmt.Sprint(i)
+			}
+			if err := c.Create(); err != nil {
+				return err
+			}
+			categories = append(categories, c)
+		}
+		ancestors, err := categories[2].Ancestors(0)
+		if err != nil {
+			return err
+		}
+		if len(ancestors) != 2 || ancestors[0].(*Category).Name != "branch" {
+			return errors.New("ancestors error")
+		}
+		if parent, _ := ancestors[0].(*Category).Parent(); parent != ancestors[1] {
+			return errors.New("ancestors parent error")
+		}
+		descendants, err := categories[0].Descendants(1)
+		if err != nil {
+			return err
+		}
+		if len(descendants) != 1 || descendants[0].(*Category).Name != "branch" {
+			return errors.New("descendants with depth error")
+		}
+		if descendants, err = categories[0].Descendants(0); err != nil {
+			return err
+		}
+		children, _ := categories[0].Children()
+		if len(descendants) != 2 || len(children) != 1 {
+			return errors.New("descendants error")
+		}
+		if grandchildren, _ := children[0].(*Category).Children(); len(grandchildren) != 1 {
+			return errors.New("descendants nested error")
+		}
+		if count := cr.Another().WhereHas("children", nil).MustCount(); count != 2 {
+			return errors.New("where has self nexus error")
+		}
+		if count := cr.Another().WhereDoesntHave("children", nil).MustCount(); count != 1 {
+			return errors.New("where doesnt have self nexus error")
+		}
+		roots := cr.Another().With("children.children").MustCollect()
+		if len(roots) != 3 {
+			return errors.New("with children error")
+		}
+		for _, root := range roots {
+			if !root.(*Category).Loaded("children") {
+				return errors.New("with children not loaded error")
+			}
+			children, _ := root.(*Category).Children()
+			for _, child := range children {
+				if !child.(*Category).Loaded("children") {
+					return errors.New("with nested children not loaded error")
+				}
+			}
+		}
+		if ancestors, err = categories[0].Ancestors(0); err != nil || len(ancestors) != 0 {
+			return errors.New("root ancestors error")
+		}
+		categories[0].ParentId = "3"
+		if err = categories[0].Update(); err != nil {
+			return err
+		}
+		if ancestors, err = categories[2].Ancestors(0); err != nil || len(ancestors) != 2 {
+			return errors.New("cyclic ancestors error")
+		}
+		if descendants, err = categories[0].Descendants(0); err != nil || len(descendants) != 2 {
+			return errors.New("cyclic descendants error")
+		}
+		return nil
+	}, t, "tree")
+}
+
+func TestInverse(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		many, err := user.Many("books")
+		if err != nil {
+			return err
+		}
+		for _, b := range many.(Collection) {
+			if !b.(*Book).Loaded("author") || b.(*Book).MustOne("author") != user {
+				return errors.New("lazy inverse error")
+			}
+		}
+		for _, m := range NewUser().Repo().With("books").MustCollect() {
+			for _, b := range m.(*User).MustMany("books").(Collection) {
+				if b.(*Book).MustOne("author") != m {
+					return errors.New("eager inverse error")
+				}
+			}
+		}
+		return nil
+	}, t, "inverse")
+}
+
+func TestHooks(t *T) {
+	suit(func(t *T) error {
+		user := New(&HookedUser{Id: "1", Name: "yang-zhong", Level: 1}).(*HookedUser)
+		if err := user.Create(); err != nil {
+			return err
+		}
+		if user.Optional != "created" {
+			return errors.New("before create hook error")
+		}
+		updated := 0
+		unlisten := Listen(new(HookedUser), AFTER_UPDATE, func(m interface{}) error {
+			updated++
+			return nil
+		})
+		defer unlisten()
+		repo := New(new(HookedUser)).(Model).Repo()
+		repo.Where("id", "1")
+		if err := repo.UpdateRaw(map[string]interface{}{"level": 2}); err != nil {
+			return err
+		}
+		if updated != 1 || repo.trashed != trashed_without {
+			return errors.New("after update listener error")
+		}
+		users := New(new(HookedUser)).(Model).Repo().MustFetch()
+		if len(users) != 1 || users[0].(*HookedUser).events["fetch"] != 1 {
+			return errors.New("after fetch hook error")
+		}
+		if err := New(new(HookedUser)).(Model).Repo().Deletes(users); err == nil {
+			return errors.New("before delete hook error")
+		}
+		if NewUser().Repo().MustCount() != 1 {
+			return errors.New("delete aborted by hook error")
+		}
+		return nil
+	}, t, "hooks")
+}
+
+func TestRawWrite(t *T) {
+	suit(func(t *T) error {
+		for _, hooked := range []bool{false, true} {
+			fired := 0
+			if hooked {
+				count := func(m interface{}) error {
+					fired++
+					return nil
+				}
+				unlisten := Listen(new(Book), AFTER_UPDATE, count)
+				defer unlisten()
+				unlisten = Listen(new(Book), AFTER_DELETE, count)
+				defer unlisten()
+			}
+			alive := New(&Book{Id: fmt.Sprintf("alive-%v", hooked), UserId: "1", Name: "book"}).(*Book)
+			trashed := New(&Book{Id: fmt.Sprintf("trashed-%v", hooked), UserId: "1", Name: "book"}).(*Book)
+			if err := alive.Create(); err != nil {
+				return err
+			}
+			if err := trashed.Create(); err != nil {
+				return err
+			}
+			if err := trashed.Delete(); err != nil {
+				return err
+			}
+			fired = 0
+			repo := NewBook().Repo()
+			repo.Where("user_id", "1")
+			if err := repo.UpdateRaw(map[string]interface{}{"name": "raw"}); err != nil {
+				return err
+			}
+			if NewBook().WithTrashed().MustFind(alive.Id).(*Book).Name != "raw" ||
+				NewBook().WithTrashed().MustFind(trashed.Id).(*Book).Name != "book" {
+				return fmt.Errorf("update raw scope error, hooked: %v", hooked)
+			}
+			repo = NewBook().Repo()
+			repo.Where("user_id", "1")
+			if err := repo.DeleteRaw(nil); err != nil {
+				return err
+			}
+			if NewBook().Repo().MustCount() != 0 || NewBook().OnlyTrashed().MustCount() != 2 {
+				return fmt.Errorf("delete raw soft delete error, hooked: %v", hooked)
+			}
+			if hooked && fired != 2 || !hooked && fired != 0 {
+				return fmt.Errorf("raw write hooks error, hooked: %v", hooked)
+			}
+			if err := NewBook().WithTrashed().ForceDeletes(NewBook().WithTrashed().MustFetch()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, t, "raw write")
+}
+
 func TestWithCustom(t *T) {
 	suit(func(t *T) error {
 		user := NewUser()
@@ -314,6 +1125,103 @@ func TestWithCustom(t *T) {
 	}, t, "with custom")
 }
 
+func TestWithCount(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		ms, err := user.Repo().WithCount("books").Fetch()
+		if err != nil {
+			return err
+		}
+		for _, m := range ms {
+			if count := m.(*User).Extra("books_count"); count != 1 {
+				return errors.New("with count error")
+			}
+		}
+		ms, err = book.Repo().WithSum("author", "age").WithMax("author", "level").Fetch()
+		if err != nil {
+			return err
+		}
+		for _, m := range ms {
+			if sum := m.(*Book).Extra("author_sum_age"); sum != 17.0 {
+				return errors.New("with sum error")
+			}
+			if max := m.(*Book).Extra("author_max_level"); max != 1 {
+				return errors.New("with max error")
+			}
+		}
+		return nil
+	}, t, "with count")
+}
+
+func TestJoinWith(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		another := NewBook()
+		another.Id = "2"
+		another.UserId = "2"
+		another.Name = "no author"
+		if err := another.Create(); err != nil {
+			return err
+		}
+		repo := NewBook().Repo().JoinWith("author")
+		repo.Where("book.id", "1")
+		ms, err := repo.Fetch()
+		if err != nil {
+			return err
+		}
+		if len(ms) != 1 || !isBook(ms[0]) || !isUser(ms[0].(*Book).MustOne("author")) {
+			return errors.New("join with error")
+		}
+		repo = NewBook().Repo().JoinWith("author")
+		repo.Where("book.id", "2")
+		if ms, err = repo.Fetch(); err != nil {
+			return err
+		}
+		if len(ms) != 1 || ms[0].(*Book).MustOne("author") != nil {
+			return errors.New("join with no author error")
+		}
+		repo = NewBook().Repo().JoinWith("author")
+		repo.Where("author.name", "yang-zhong")
+		if ms, err = repo.Fetch(); err != nil {
+			return err
+		}
+		if len(ms) != 1 || ms[0].(*Book).Id != "1" {
+			return errors.New("join with alias condition error")
+		}
+		if _, err = NewUser().Repo().JoinWith("books").Fetch(); err == nil {
+			return errors.New("join with has many nexus error")
+		}
+		b := NewBook()
+		b.DeclareOne("adult_author", new(User), Nexus{"id": "user_id", "age": NWhere{">=", 18}})
+		repo = b.Repo().JoinWith("adult_author")
+		repo.Where("book.id", "1")
+		if ms, err = repo.Fetch(); err != nil {
+			return err
+		}
+		if len(ms) != 1 || ms[0].(*Book).MustOne("adult_author") != nil {
+			return errors.New("join with filtered target error")
+		}
+		if err = book.Delete(); err != nil {
+			return err
+		}
+		u := NewUser()
+		u.DeclareOne("book", new(Book), Nexus{"user_id": "id"})
+		if ms, err = u.Repo().JoinWith("book").Fetch(); err != nil {
+			return err
+		}
+		if len(ms) != 1 || ms[0].(*User).MustOne("book") != nil {
+			return errors.New("join with trashed target error")
+		}
+		return nil
+	}, t, "join with")
+}
+
 func TestWithOne(t *T) {
 	suit(func(t *T) error {
 		user := NewUser()
@@ -366,6 +1274,148 @@ func TestTx(t *T) {
 	}, t, "tx")
 }
 
+func TestSoftDelete(t *T) {
+	suit(func(t *T) error {
+		book := NewBook()
+		insertBook(book)
+		if err := book.Delete(); err != nil {
+			return err
+		}
+		if !book.Trashed() {
+			return errors.New("soft delete error")
+		}
+		if count := NewBook().Repo().MustCount(); count != 0 {
+			return errors.New("trashed row not excluded")
+		}
+		if count := book.WithTrashed().MustCount(); count != 1 {
+			return errors.New("with trashed error")
+		}
+		if count := book.OnlyTrashed().MustCount(); count != 1 {
+			return errors.New("only trashed error")
+		}
+		if err := book.Restore(); err != nil {
+			return err
+		}
+		if count := NewBook().Repo().MustCount(); count != 1 {
+			return errors.New("restore error")
+		}
+		if err := book.ForceDelete(); err != nil {
+			return err
+		}
+		if count := book.WithTrashed().MustCount(); count != 0 {
+			return errors.New("force delete error")
+		}
+		return nil
+	}, t, "soft delete")
+}
+
+func TestRaw(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		ms, err := user.Repo().With("books").Raw("SELECT * FROM user WHERE age > ?", 10)
+		if err != nil {
+			return err
+		}
+		if len(ms) != 1 || !isUser(ms[0]) {
+			return errors.New("raw error")
+		}
+		return nil
+	}, t, "raw")
+}
+
+func TestScanInto(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		type report struct {
+			UserId string `db:"user_id"`
+			Books  int    `db:"books"`
+		}
+		rows, err := user.DB().Query("SELECT user_id, count(1) AS books, max(name) AS extra FROM book GROUP BY user_id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		reports := []*report{}
+		if err = ScanInto(&reports, rows); err != nil {
+			return err
+		}
+		if len(reports) != 1 || reports[0].UserId != "1" || reports[0].Books != 1 {
+			return errors.New("scan into error")
+		}
+		return nil
+	}, t, "scan into")
+}
+
+func TestLockForUpdate(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		insertUser(user)
+		if _, err := user.Repo().Another().LockForUpdate().Fetch(); err == nil {
+			return errors.New("lock outside tx error")
+		}
+		return user.DB().Tx(func(_ *sql.Tx) error {
+			if _, ok, err := user.Repo().Another().LockForUpdate().Find("1"); err != nil {
+				return err
+			} else if !ok {
+				return errors.New("lock for update error")
+			}
+			_, err := user.Repo().Another().SharedLock().NoWait().Fetch()
+			return err
+		})
+	}, t, "lock for update")
+}
+
+func TestExplain(t *T) {
+	suit(func(t *T) error {
+		user := NewUser()
+		book := NewBook()
+		insertUser(user)
+		insertBook(book)
+		repo := NewUser().Repo().With("books")
+		repo.Where("age", ">", 10)
+		stmt, err := repo.ToSQL()
+		if err != nil {
+			return err
+		}
+		if len(stmt.Params) != 1 || stmt.String() == stmt.SQL {
+			return errors.New("to sql error")
+		}
+		plans, err := repo.Explain()
+		if err != nil {
+			return err
+		}
+		if len(plans) != 2 || plans[1].Nexus != "books" || len(plans[1].Rows) == 0 {
+			return errors.New("explain error")
+		}
+		fetched := 0
+		unlisten := Listen(new(User), AFTER_FETCH, func(m interface{}) error {
+			fetched++
+			return nil
+		})
+		defer unlisten()
+		stmts, err := NewUser().Repo().With("books.author").WithCount("books").FetchStatements()
+		if err != nil {
+			return err
+		}
+		nexus := []string{}
+		for _, stmt := range stmts {
+			nexus = append(nexus, stmt.Nexus)
+		}
+		if strings.Join(nexus, ",") != ",books,books.author,books_count" {
+			return errors.New("fetch statements error: " + strings.Join(nexus, ","))
+		}
+		// the main query is run to find the books, the leaf author query is not
+		if fetched != 1 {
+			return errors.New("fetch statements side effect error")
+		}
+		return nil
+	}, t, "explain")
+}
+
 func TestFind(t *T) {
 	suit(func(t *T) error {
 		user := NewUser()
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	morphTargets map[string]interface{}  // morph type to model
+	morphAliases map[reflect.Type]string // model type to morph type
+	morphTables  map[string]interface{}  // table name to model declaring morph nexus
+	morphLock    sync.RWMutex
+)
+
+func init() {
+	morphTargets = make(map[string]interface{})
+	morphAliases = make(map[reflect.Type]string)
+	morphTables = make(map[string]interface{})
+}
+
+// RegisterMorph register model m as the target of morph type alias, which is
+// stored in the type col of morph relationships. a model not registered
+// takes its table name as the morph type, and is found by it once it has
+// declared a morph many or morph one nexus
+func RegisterMorph(alias string, m interface{}) {
+	morphLock.Lock()
+	defer morphLock.Unlock()
+	morphTargets[alias] = m
+	morphAliases[reflect.TypeOf(m)] = alias
+}
+
+// morphAlias find the morph type of model m
+func morphAlias(m interface{}) string {
+	morphLock.RLock()
+	defer morphLock.RUnlock()
+	if alias, ok := morphAliases[reflect.TypeOf(m)]; ok {
+		return alias
+	}
+	return m.(Model).TableName()
+}
+
+// morphType is the morph type of model m found when it's used as a sql param,
+// so a model registered by RegisterMorph after its nexus declared is typed
+// by the registered alias too
+type morphType struct {
+	m interface{}
+}
+
+func (mt morphType) Value() (driver.Value, error) {
+	return morphAlias(mt.m), nil
+}
+
+// morphTarget find the model of morph type alias, registered or typed by its
+// table name, false if unknown
+func morphTarget(alias string) (interface{}, bool) {
+	morphLock.RLock()
+	defer morphLock.RUnlock()
+	if m, ok := morphTargets[alias]; ok {
+		return m, true
+	}
+	if m, ok := morphTables[alias]; ok {
+		if _, registered := morphAliases[reflect.TypeOf(m)]; !registered {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// DeclareMorphMany declare a has many relationship to target whose prefix_id
+// col refers to the pk of model and prefix_type col is the morph type of model
+//
+//	user.DeclareMorphMany("comments", new(Comment), "commentable")
+func (base *Base) DeclareMorphMany(name string, target interface{}, prefix string) {
+	base.DeclareMany(name, target, base.morphNexus(prefix))
+}
+
+// DeclareMorphOne declare a has one relationship like DeclareMorphMany
+func (base *Base) DeclareMorphOne(name string, target interface{}, prefix string) {
+	base.DeclareOne(name, target, base.morphNexus(prefix))
+}
+
+// DeclareMorphTo declare the inverse of DeclareMorphMany and DeclareMorphOne,
+// the target is the model registered as the morph type in prefix_type col
+//
+//	comment.DeclareMorphTo("commentable", "commentable")
+func (base *Base) DeclareMorphTo(name string, prefix string) {
+	base.relations[name] = relationship{t: t_morph, morph: prefix}
+}
+
+func (base *Base) morphNexus(prefix string) Nexus {
+	morphLock.Lock()
+	morphTables[base.mapper.model.(Model).TableName()] = base.mapper.model
+	morphLock.Unlock()
+	return Nexus{
+		prefix + "_id":   base.mapper.pk,
+		prefix + "_type": NWhere{"=", morphType{base.mapper.model}},
+	}
+}
+
+// morphNexusValues bind the targets of each morph type
+type morphNexusValues struct {
+	prefix string
+	values map[string]NexusValues // nexus values of each morph type
+	pks    map[string]string      // pk of each morph type
+}
+
+func (mv *morphNexusValues) DataOf(m interface{}, _ Nexus) interface{} {
+	alias, _ := m.(Mapable).Mapper().colValue(m, mv.prefix+"_type")
+	key := fmt.Sprint(alias)
+	if data, ok := mv.values[key]; ok {
+		return data.DataOf(m, Nexus{mv.pks[key]: mv.prefix + "_id"})
+	}
+	// no target of an empty or unknown morph type
+	return Collection{}
+}
+
+// morphValues fetch the targets of models, one query a morph type
+func (repo *Repo) morphValues(w with, models []interface{}) (NexusValues, error) {
+	nms, err := repo.morphModels(w, models)
+	if err != nil {
+		return nil, err
+	}
+	mv := &morphNexusValues{
+		prefix: w.morph,
+		values: make(map[string]NexusValues),
+		pks:    make(map[string]string),
+	}
+	for alias, nm := range nms {
+		if mv.values[alias], err = w.handler(nm); err != nil {
+			return nil, err
+		}
+		mv.pks[alias] = nm.(Model).PK()
+	}
+
+	return mv, nil
+}
+
+// morphModels group models by morph type, and new the target model of each
+// morph type whose repo is conditioned to find the targets of the group. an
+// empty or unknown morph type is skipped, the models of it have no target
+func (repo *Repo) morphModels(w with, models []interface{}) (nms map[string]interface{}, err error) {
+	mapper := repo.model.(Mapable).Mapper()
+	ids := make(map[string][]interface{})
+	for _, m := range models {
+		var alias, id interface{}
+		if alias, err = mapper.colValue(m, w.morph+"_type"); err != nil {
+			return
+		}
+		if id, err = mapper.colValue(m, w.morph+"_id"); err != nil {
+			return
+		}
+		if key := fmt.Sprint(alias); key != "" {
+			ids[key] = append(ids[key], id)
+		}
+	}
+	nms = make(map[string]interface{})
+	for alias, vals := range ids {
+		target, ok := morphTarget(alias)
+		if !ok {
+			continue
+		}
+		nm := newOf(target)
+		r := nm.(Model).Repo()
+		r.WhereIn(nm.(Model).PK(), vals)
+		w.constrain(r)
+		nms[alias] = nm
+	}
+
+	return
+}
+
+// morphAliasesOf sort the morph types of nms
+func morphAliasesOf(nms map[string]interface{}) []string {
+	aliases := []string{}
+	for alias := range nms {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"reflect"
+	. "testing"
+)
+
+func TestMorphType(t *T) {
+	mt := morphType{new(FilterUser)}
+	if v, _ := mt.Value(); v != "users" {
+		t.Fatal("morph type of unregistered model error")
+	}
+	RegisterMorph("user", new(FilterUser))
+	defer func() {
+		morphLock.Lock()
+		defer morphLock.Unlock()
+		delete(morphTargets, "user")
+		delete(morphAliases, reflect.TypeOf(new(FilterUser)))
+	}()
+	if v, _ := mt.Value(); v != "user" {
+		t.Fatal("morph type registered after declared error")
+	}
+	if sqlang := interpolate(dialect_mysql, "type = ?", []interface{}{mt}); sqlang != "type = 'user'" {
+		t.Fatal("morph type literal error")
+	}
+}
+
+func TestMorphTarget(t *T) {
+	if _, ok := morphTarget("unknown"); ok {
+		t.Fatal("unknown morph type error")
+	}
+	m := New(new(FilterUser)).(*FilterUser)
+	m.morphNexus("owner")
+	defer func() {
+		morphLock.Lock()
+		defer morphLock.Unlock()
+		delete(morphTables, "users")
+	}()
+	if target, ok := morphTarget("users"); !ok || reflect.TypeOf(target) != reflect.TypeOf(m) {
+		t.Fatal("morph type of table name error")
+	}
+}
@@ -0,0 +1,360 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	. "github.com/yang-zzhong/go-querybuilder"
+	"sort"
+)
+
+// set the pivot row of a model fetched by belongs to many relationship
+type pivotable interface {
+	SetPivot(row map[string]interface{})
+}
+
+// pivotNexusValues bind targets to models by the pivot rows, each model get
+// a copy of the target with the pivot row between them
+type pivotNexusValues struct {
+	pivot   *pivot
+	rows    map[string][]map[string]interface{} // pivot rows keyed by local cols
+	targets map[string]interface{}              // targets keyed by foreign cols
+	order   map[string]int                      // order of targets in query
+}
+
+func (pv *pivotNexusValues) DataOf(m interface{}, _ Nexus) interface{} {
+	rows := []map[string]interface{}{}
+	for _, row := range pv.rows[modelKey(pv.pivot.local, m)] {
+		if _, ok := pv.targets[rowKey(pv.pivot.foreign, row)]; ok {
+			rows = append(rows, row)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return pv.order[rowKey(pv.pivot.foreign, rows[i])] < pv.order[rowKey(pv.pivot.foreign, rows[j])]
+	})
+	result := Collection{}
+	for _, row := range rows {
+		c := clone(pv.targets[rowKey(pv.pivot.foreign, row)])
+		c.(pivotable).SetPivot(row)
+		result = append(result, c)
+	}
+	return result
+}
+
+// pivotValues fetch the pivot rows and the targets of models
+func (repo *Repo) pivotValues(w with, models []interface{}) (NexusValues, error) {
+	nm, rows, err := repo.pivotModel(w, models)
+	if err != nil {
+		return nil, err
+	}
+	pv := &pivotNexusValues{
+		pivot:   w.pivot,
+		rows:    make(map[string][]map[string]interface{}),
+		targets: make(map[string]interface{}),
+		order:   make(map[string]int),
+	}
+	if len(rows) == 0 {
+		return pv, nil
+	}
+	data, err := w.handler(nm)
+	if err != nil {
+		return nil, err
+	}
+	dv, ok := data.(*DefaultNexusValues)
+	if !ok {
+		// a custom handler binds the targets itself
+		return data, nil
+	}
+	for _, row := range rows {
+		key := rowKey(w.pivot.local, row)
+		pv.rows[key] = append(pv.rows[key], row)
+	}
+	for i, target := range dv.data {
+		key := modelKey(w.pivot.foreign, target)
+		pv.targets[key] = target
+		pv.order[key] = i
+	}
+
+	return pv, nil
+}
+
+// pivotModel fetch the pivot rows of models, and new the target model whose
+// repo is conditioned to find the targets of the pivot rows
+func (repo *Repo) pivotModel(w with, models []interface{}) (nm interface{}, rows []map[string]interface{}, err error) {
+	var b *Builder
+	if b, err = repo.pivotQuery(w, models); err != nil {
+		return
+	}
+	err = repo.query(b.ForQuery(), b.Params(), func(r *sql.Rows, columns []string) error {
+		row, err := scanMap(r, columns)
+		if err == nil {
+			rows = append(rows, row)
+		}
+		return err
+	})
+	if err != nil {
+		return
+	}
+	nm = New(w.m)
+	r := nm.(Model).Repo()
+	for _, pc := range nexusCols(w.pivot.foreign) {
+		vals := []interface{}{}
+		for _, row := range rows {
+			vals = append(vals, row[pc])
+		}
+		if len(vals) != 0 {
+			r.WhereIn(w.pivot.foreign[pc].(string), vals)
+		}
+	}
+	w.constrain(r)
+
+	return
+}
+
+// pivotQuery new the builder of the query finding the pivot rows of models
+func (repo *Repo) pivotQuery(w with, models []interface{}) (b *Builder, err error) {
+	b = NewBuilder(repo.modifier)
+	b.From(w.pivot.table)
+	for pc, mc := range w.pivot.local {
+		switch v := mc.(type) {
+		case NWhere:
+			b.Where(pc, v.Op, v.Value)
+		case string:
+			vals := []interface{}{}
+			for _, m := range models {
+				var val interface{}
+				if val, err = repo.model.(Mapable).Mapper().colValue(m, v); err != nil {
+					return
+				}
+				vals = append(vals, val)
+			}
+			b.WhereIn(pc, vals)
+		}
+	}
+	for pc, tc := range w.pivot.foreign {
+		if v, ok := tc.(NWhere); ok {
+			b.Where(pc, v.Op, v.Value)
+		}
+	}
+
+	return
+}
+
+// Attach insert the pivot rows between model and the targets of ids of
+// belongs to many nexus name, data is the extra cols of each pivot row
+func (base *Base) Attach(name string, ids []interface{}, data map[string]interface{}) error {
+	p, fk, err := base.pivotOf(name)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	defer delete(base.manysValue, name)
+	return base.DB().transaction(func(_ *sql.Tx) error {
+		return base.attach(p, fk, ids, data)
+	})
+}
+
+// Detach delete the pivot rows between model and the targets of ids of
+// belongs to many nexus name, all pivot rows of model if ids is empty
+func (base *Base) Detach(name string, ids []interface{}) error {
+	p, fk, err := base.pivotOf(name)
+	if err != nil {
+		return err
+	}
+	defer delete(base.manysValue, name)
+	return base.DB().transaction(func(_ *sql.Tx) error {
+		return base.detach(p, fk, ids)
+	})
+}
+
+// Sync make the targets of ids the only targets attached to model of
+// belongs to many nexus name
+func (base *Base) Sync(name string, ids []interface{}) error {
+	return base.syncPivot(name, ids, false)
+}
+
+// Toggle detach the attached targets of ids, attach the others of ids
+func (base *Base) Toggle(name string, ids []interface{}) error {
+	return base.syncPivot(name, ids, true)
+}
+
+func (base *Base) syncPivot(name string, ids []interface{}, toggle bool) error {
+	p, fk, err := base.pivotOf(name)
+	if err != nil {
+		return err
+	}
+	defer delete(base.manysValue, name)
+	return base.DB().transaction(func(_ *sql.Tx) error {
+		attached, err := base.attached(p, fk)
+		if err != nil {
+			return err
+		}
+		wanted := make(map[string]bool)
+		attach, detach := []interface{}{}, []interface{}{}
+		for _, id := range ids {
+			key := nexusKey(id)
+			wanted[key] = true
+			if _, ok := attached[key]; !ok {
+				attach = append(attach, id)
+			} else if toggle {
+				detach = append(detach, id)
+			}
+		}
+		if !toggle {
+			for key, id := range attached {
+				if !wanted[key] {
+					detach = append(detach, id)
+				}
+			}
+		}
+		if len(detach) != 0 {
+			if err = base.detach(p, fk, detach); err != nil {
+				return err
+			}
+		}
+		if len(attach) != 0 {
+			return base.attach(p, fk, attach, nil)
+		}
+		return nil
+	})
+}
+
+func (base *Base) attach(p *pivot, fk string, ids []interface{}, data map[string]interface{}) error {
+	rows := []map[string]interface{}{}
+	for _, id := range ids {
+		row := make(map[string]interface{})
+		for col, val := range data {
+			row[col] = val
+		}
+		for _, n := range []Nexus{p.local, p.foreign} {
+			for pc, c := range n {
+				if v, ok := c.(NWhere); ok && v.Op == "=" {
+					row[pc] = v.Value
+				}
+			}
+		}
+		for _, pc := range nexusCols(p.local) {
+			val, err := base.fieldValue(p.local[pc].(string))
+			if err != nil {
+				return err
+			}
+			row[pc] = val
+		}
+		row[fk] = id
+		rows = append(rows, row)
+	}
+	b := NewBuilder(GetModifier(base.DBSelector()))
+	b.From(p.table)
+	_, err := base.DB().Exec(b.ForInsert(rows), b.Params()...)
+
+	return err
+}
+
+func (base *Base) detach(p *pivot, fk string, ids []interface{}) error {
+	b, err := base.pivotBuilder(p)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 0 {
+		b.WhereIn(fk, ids)
+	}
+	_, err = base.DB().Exec(b.ForRemove(), b.Params()...)
+
+	return err
+}
+
+// attached find the foreign col values of the pivot rows of model, keyed by nexus key
+func (base *Base) attached(p *pivot, fk string) (map[string]interface{}, error) {
+	b, err := base.pivotBuilder(p)
+	if err != nil {
+		return nil, err
+	}
+	b.Select(fk)
+	rows, err := base.DB().Query(b.ForQuery(), b.Params()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]interface{})
+	for rows.Next() {
+		var id interface{}
+		if err = rows.Scan(&id); err != nil {
+			return nil, &Error{ERR_SCAN, err}
+		}
+		if b, ok := id.([]byte); ok {
+			id = string(b)
+		}
+		result[nexusKey(id)] = id
+	}
+
+	return result, rows.Err()
+}
+
+// pivotBuilder new a builder of the pivot table conditioned to the pivot rows of model
+func (base *Base) pivotBuilder(p *pivot) (*Builder, error) {
+	b := NewBuilder(GetModifier(base.DBSelector()))
+	b.From(p.table)
+	for _, n := range []Nexus{p.local, p.foreign} {
+		for pc, c := range n {
+			if v, ok := c.(NWhere); ok {
+				b.Where(pc, v.Op, v.Value)
+			}
+		}
+	}
+	for _, pc := range nexusCols(p.local) {
+		val, err := base.fieldValue(p.local[pc].(string))
+		if err != nil {
+			return nil, err
+		}
+		b.Where(pc, val)
+	}
+
+	return b, nil
+}
+
+// pivotOf find the pivot of belongs to many nexus name, and the only pivot
+// col referring the target
+func (base *Base) pivotOf(name string) (p *pivot, fk string, err error) {
+	rel, ok := base.relation(name)
+	if !ok || rel.t != t_pivot {
+		err = &Error{ERR_NEXUS_UNDEFINED, errors.New("belongs to many nexus " + name + " not exists")}
+		return
+	}
+	p = rel.pivot
+	if cols := nexusCols(p.foreign); len(cols) == 1 {
+		fk = cols[0]
+	} else {
+		err = &Error{ERR_NEXUS_UNDEFINED, errors.New("pivot " + p.table + " must have one col referring target")}
+	}
+
+	return
+}
+
+// nexusCols find the sorted cols of n related to a col
+func nexusCols(n Nexus) []string {
+	cols := []string{}
+	for col, c := range n {
+		if _, ok := c.(string); ok {
+			cols = append(cols, col)
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// rowKey make the nexus key of the pivot row by the related cols of n
+func rowKey(n Nexus, row map[string]interface{}) string {
+	vals := []interface{}{}
+	for _, col := range nexusCols(n) {
+		vals = append(vals, row[col])
+	}
+	return nexusKey(vals...)
+}
+
+// modelKey make the nexus key of the model by the cols related by n
+func modelKey(n Nexus, m interface{}) string {
+	vals := []interface{}{}
+	for _, col := range nexusCols(n) {
+		val, _ := m.(Mapable).Mapper().colValue(m, n[col].(string))
+		vals = append(vals, val)
+	}
+	return nexusKey(vals...)
+}
@@ -0,0 +1,300 @@
+package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// push model and it's loaded relationship values
+type pushable interface {
+	push(pushed map[interface{}]bool) Errors
+	assign(colname string, val interface{}) error
+}
+
+// Push save model and the has one and has many values set by SetOne, SetMany
+// or fetched, and their values, in one tx of model's db. the target of a has
+// one nexus referred by model, like the author of a book, is saved before
+// model and its key is copied to model, so is the target of a morph to nexus
+// with its morph type. the others are saved after model with the key of
+// model copied to them. the targets of a belongs to many nexus are attached
+// to model if not yet, the targets of a through nexus are saved as they are,
+// the through models untouched. errors of all models are returned as Errors
+// and the tx is rolled back
+func (base *Base) Push() error {
+	return base.DB().transaction(func(_ *sql.Tx) error {
+		if errs := base.push(make(map[interface{}]bool)); len(errs) != 0 {
+			return errs
+		}
+		return nil
+	})
+}
+
+func (base *Base) push(pushed map[interface{}]bool) (errs Errors) {
+	pushed[base.mapper.model] = true
+	after := []string{}
+	for name, rel := range base.ones {
+		one, ok := base.onesValue[name]
+		if !ok || one == nil || pushed[one] {
+			continue
+		}
+		if !referred(rel) {
+			after = append(after, name)
+			continue
+		}
+		if e := one.(pushable).push(pushed); len(e) != 0 {
+			errs = append(errs, e...)
+			continue
+		}
+		if err := base.refer(rel, one); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for name, rel := range base.relations {
+		one, ok := base.onesValue[name]
+		if rel.t != t_morph || !ok || one == nil || pushed[one] {
+			continue
+		}
+		if e := one.(pushable).push(pushed); len(e) != 0 {
+			errs = append(errs, e...)
+			continue
+		}
+		if err := base.referMorph(rel, one); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return
+	}
+	if err := base.Save(); err != nil {
+		return Errors{err}
+	}
+	for _, name := range after {
+		errs = append(errs, base.pushNexus(base.ones[name], []interface{}{base.onesValue[name]}, pushed)...)
+	}
+	for name, rel := range base.manys {
+		if many, ok := base.manysValue[name]; ok {
+			errs = append(errs, base.pushNexus(rel, nexusItems(many), pushed)...)
+		}
+	}
+	for name, rel := range base.relations {
+		var targets []interface{}
+		if many, ok := base.manysValue[name]; ok && toMany(rel.t) {
+			targets = nexusItems(many)
+		} else if one, ok := base.onesValue[name]; ok && rel.t == t_one_through {
+			targets = []interface{}{one}
+		}
+		errs = append(errs, base.pushRelation(name, rel, targets, pushed)...)
+	}
+
+	return
+}
+
+// pushNexus copy the key of model to the targets and push them
+func (base *Base) pushNexus(rel relationship, targets []interface{}, pushed map[interface{}]bool) (errs Errors) {
+	for _, target := range targets {
+		if target == nil || pushed[target] {
+			continue
+		}
+		if err := base.relate(rel, target); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, target.(pushable).push(pushed)...)
+	}
+
+	return
+}
+
+// pushRelation push the targets of a belongs to many or through nexus, and
+// attach the targets of a belongs to many nexus not attached yet
+func (base *Base) pushRelation(name string, rel relationship, targets []interface{}, pushed map[interface{}]bool) (errs Errors) {
+	for _, target := range targets {
+		if target != nil && !pushed[target] {
+			errs = append(errs, target.(pushable).push(pushed)...)
+		}
+	}
+	if len(errs) != 0 || rel.t != t_pivot || len(targets) == 0 {
+		return
+	}
+	p, fk, err := base.pivotOf(name)
+	if err != nil {
+		return Errors{err}
+	}
+	attached, err := base.attached(p, fk)
+	if err != nil {
+		return Errors{err}
+	}
+	ids := []interface{}{}
+	for _, target := range targets {
+		if target == nil {
+			continue
+		}
+		id := target.(Model).Get(p.foreign[fk].(string))
+		if _, ok := attached[nexusKey(id)]; !ok {
+			attached[nexusKey(id)] = id
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) != 0 {
+		if err = base.attach(p, fk, ids, nil); err != nil {
+			return Errors{err}
+		}
+	}
+
+	return
+}
+
+// referMorph copy the key and the morph type of target to model
+func (base *Base) referMorph(rel relationship, target interface{}) error {
+	if err := base.assign(rel.morph+"_id", target.(Model).Get(target.(Model).PK())); err != nil {
+		return err
+	}
+	return base.assign(rel.morph+"_type", morphAlias(target))
+}
+
+// refer copy the key of target to model, model refers target
+func (base *Base) refer(rel relationship, target interface{}) error {
+	for af, bf := range rel.n {
+		if col, ok := bf.(string); ok {
+			if err := base.assign(col, target.(Model).Get(af)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// relate copy the key of model and the equal conditions of nexus to target
+func (base *Base) relate(rel relationship, target interface{}) error {
+	for af, bf := range rel.n {
+		var err error
+		switch v := bf.(type) {
+		case string:
+			err = target.(pushable).assign(af, base.Get(v))
+		case NWhere:
+			if v.Op == "=" {
+				err = target.(pushable).assign(af, v.Value)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// referred tell if the nexus cols of target include the pk of target, then
+// model refers the target, like the author of a book
+func referred(rel relationship) bool {
+	pk := newOf(rel.target).(Model).PK()
+	_, ok := rel.n[pk]
+	return ok
+}
+
+// nexusItems list the models of a has many value
+func nexusItems(many interface{}) []interface{} {
+	switch v := many.(type) {
+	case Collection:
+		return v
+	case map[interface{}]interface{}:
+		items := []interface{}{}
+		for _, item := range v {
+			items = append(items, item)
+		}
+		return items
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+// assign set col value converted to the field type. integers and strings
+// are converted to each other by strconv, numbers to numbers, strings to
+// time, a field which is a sql.Scanner scans the value, the other
+// mismatches are rejected
+func (base *Base) assign(colname string, val interface{}) error {
+	fd, ok := base.mapper.fd(colname)
+	if !ok {
+		return &Error{ERR_COL_UNDEFINED, errors.New("col " + colname + " not defined on model")}
+	}
+	field := base.mapper.value.FieldByName(fd.fieldname)
+	value := reflect.ValueOf(val)
+	if !value.IsValid() {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if value.Type().AssignableTo(field.Type()) {
+		field.Set(value)
+		return nil
+	}
+	if valuer, ok := val.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return err
+		}
+		return base.assign(colname, v)
+	}
+	if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(val)
+	}
+	if b, ok := val.([]byte); ok {
+		value = reflect.ValueOf(string(b))
+	}
+	if _, ok := field.Interface().(time.Time); ok && value.Kind() == reflect.String {
+		at, err := parseTime(value.String())
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(at))
+		return nil
+	}
+	converted, err := convert(value, field.Type())
+	if err != nil {
+		return errors.New("value of col " + colname + " not a " + field.Type().String())
+	}
+	field.Set(converted)
+
+	return nil
+}
+
+// convert value to type t if both are strings or numbers
+func convert(value reflect.Value, t reflect.Type) (reflect.Value, error) {
+	from, to := kindOf(value.Type()), kindOf(t)
+	switch {
+	case from == reflect.Invalid || to == reflect.Invalid:
+		return value, errors.New("inconvertible")
+	case from == to || (from != reflect.String && to != reflect.String):
+		return value.Convert(t), nil
+	case from == reflect.Int:
+		return reflect.ValueOf(strconv.FormatInt(value.Int(), 10)).Convert(t), nil
+	case from == reflect.Uint:
+		return reflect.ValueOf(strconv.FormatUint(value.Uint(), 10)).Convert(t), nil
+	case to == reflect.Int:
+		i, err := strconv.ParseInt(value.String(), 10, t.Bits())
+		return reflect.ValueOf(i).Convert(t), err
+	case to == reflect.Uint:
+		u, err := strconv.ParseUint(value.String(), 10, t.Bits())
+		return reflect.ValueOf(u).Convert(t), err
+	}
+	return value, errors.New("inconvertible")
+}
+
+// kindOf group the kind of t to reflect.String, reflect.Int, reflect.Uint,
+// reflect.Float64, or reflect.Invalid if not a string or number
+func kindOf(t reflect.Type) reflect.Kind {
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.String
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.Uint
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return reflect.Invalid
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	. "testing"
+)
+
+func TestAssign(t *T) {
+	user := New(new(FilterUser)).(*FilterUser)
+	if err := user.assign("age", "18"); err != nil || user.Age != 18 {
+		t.Fatal("assign string to int error")
+	}
+	if err := user.assign("id", 5); err != nil || user.Id != "5" {
+		t.Fatal("assign int to string error")
+	}
+	if err := user.assign("age", int64(20)); err != nil || user.Age != 20 {
+		t.Fatal("assign int64 to int error")
+	}
+	for _, val := range []interface{}{"eighteen", true} {
+		if err := user.assign("age", val); err == nil {
+			t.Fatalf("assign %v to int should fail", val)
+		}
+	}
+}
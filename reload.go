@@ -0,0 +1,80 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Loaded tell if nexus name of model is fetched or set
+func (base *Base) Loaded(name string) bool {
+	if _, ok := base.onesValue[name]; ok {
+		return true
+	}
+	_, ok := base.manysValue[name]
+	return ok
+}
+
+// Unload drop the fetched or set value of nexus name, it's fetched again by One or Many
+func (base *Base) Unload(name string) {
+	delete(base.onesValue, name)
+	delete(base.manysValue, name)
+}
+
+// Reload fetch nexus name of model again
+func (base *Base) Reload(name string) (interface{}, error) {
+	rel, ok := base.relation(name)
+	if !ok {
+		return nil, &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + name + " not exists")}
+	}
+	base.Unload(name)
+	if toMany(rel.t) {
+		return base.Many(name)
+	}
+	return base.One(name)
+}
+
+// Refresh read all cols of model from db again, and drop the values of nexus,
+// the extras and the pivot row fetched with model
+func (base *Base) Refresh() error {
+	cols := []string{}
+	base.mapper.each(func(fd *fieldDescriptor) bool {
+		cols = append(cols, fd.colname)
+		return true
+	})
+	if err := base.refresh(cols...); err != nil {
+		return err
+	}
+	base.onesValue = make(map[string]interface{})
+	base.manysValue = make(map[string]interface{})
+	base.extras = make(map[string]interface{})
+	base.pivotValue = nil
+
+	return nil
+}
+
+// SaveRelated save target as a value of has one or has many nexus name and
+// drop the fetched value of name. the key of model is copied to target, or
+// the key of target is copied to model and model is saved too if model
+// refers target, like the author of a book
+func (base *Base) SaveRelated(name string, target interface{}) error {
+	rel, ok := base.relation(name)
+	if !ok || (rel.t != t_one && rel.t != t_many) {
+		return &Error{ERR_NEXUS_UNDEFINED, errors.New("has one or has many nexus " + name + " not exists")}
+	}
+	defer base.Unload(name)
+	return base.DB().transaction(func(_ *sql.Tx) error {
+		if rel.t == t_one && referred(rel) {
+			if err := target.(Model).Save(); err != nil {
+				return err
+			}
+			if err := base.refer(rel, target); err != nil {
+				return err
+			}
+			return base.Save()
+		}
+		if err := base.relate(rel, target); err != nil {
+			return err
+		}
+		return target.(Model).Save()
+	})
+}
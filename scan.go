@@ -0,0 +1,69 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ScanInto scan rows into dest, a pointer to a slice of struct or struct pointer.
+// cols are mapped to struct fields by the col name of db tag, a Base is not
+// required. cols without a field are discarded. ScanInto doesn't close rows
+func ScanInto(dest interface{}, rows *sql.Rows) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Slice {
+		return errors.New("dest must be a pointer to slice")
+	}
+	slice := value.Elem()
+	elem := slice.Type().Elem()
+	isptr := elem.Kind() == reflect.Ptr
+	if isptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return errors.New("dest must be a pointer to slice of struct")
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	fields := tagFields(elem)
+	for rows.Next() {
+		item := reflect.New(elem)
+		pointers := make([]interface{}, len(columns))
+		for i, colname := range columns {
+			if index, ok := fields[colname]; ok {
+				pointers[i] = item.Elem().FieldByIndex(index).Addr().Interface()
+			} else {
+				pointers[i] = new(interface{})
+			}
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return &Error{ERR_SCAN, err}
+		}
+		if isptr {
+			slice.Set(reflect.Append(slice, item))
+		} else {
+			slice.Set(reflect.Append(slice, item.Elem()))
+		}
+	}
+
+	return rows.Err()
+}
+
+// tagFields map col name of db tag to the struct field index
+func tagFields(t reflect.Type) map[string][]int {
+	result := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		td := field.Tag.Get("db")
+		if td == "" {
+			continue
+		}
+		colname := strings.Trim(strings.Split(td, "|")[0], " ")
+		result[colname] = field.Index
+	}
+
+	return result
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	. "github.com/yang-zzhong/go-querybuilder"
+	"strings"
+)
+
+// the model between model and target of a has many or has one through relationship
+type through struct {
+	m      interface{} // through model
+	first  Nexus       // through col to model col
+	second Nexus       // target col to through col
+}
+
+// DeclareManyThrough declare a has many relationship to target through the
+// model through. first map through cols to model cols, second map target
+// cols to through cols
+//
+//	country.DeclareManyThrough("posts", new(Post), new(User),
+//		Nexus{"country_id": "id"}, Nexus{"user_id": "id"})
+func (base *Base) DeclareManyThrough(name string, target, through interface{}, first, second Nexus) {
+	base.declareThrough(name, target, through, first, second, t_many_through)
+}
+
+// DeclareOneThrough declare a has one relationship like DeclareManyThrough
+func (base *Base) DeclareOneThrough(name string, target, through interface{}, first, second Nexus) {
+	base.declareThrough(name, target, through, first, second, t_one_through)
+}
+
+func (base *Base) declareThrough(name string, target, m interface{}, first, second Nexus, t int) {
+	base.relations[name] = relationship{
+		target:  target,
+		t:       t,
+		through: &through{m, first, second},
+	}
+}
+
+// throughNexusValues bind targets to models by the through cols selected
+// with targets as extras
+type throughNexusValues struct {
+	first Nexus
+	data  map[string]Collection // targets keyed by through cols
+}
+
+func (tv *throughNexusValues) DataOf(m interface{}, _ Nexus) interface{} {
+	return append(Collection{}, tv.data[modelKey(tv.first, m)]...)
+}
+
+// throughValues fetch the targets of models in one query joined the through table
+func (repo *Repo) throughValues(w with, models []interface{}) (NexusValues, error) {
+	nm, err := repo.throughModel(w, models)
+	if err != nil {
+		return nil, err
+	}
+	data, err := w.handler(nm)
+	if err != nil {
+		return nil, err
+	}
+	dv, ok := data.(*DefaultNexusValues)
+	if !ok {
+		// a custom handler binds the targets itself
+		return data, nil
+	}
+	tv := &throughNexusValues{w.through.first, make(map[string]Collection)}
+	cols := nexusCols(w.through.first)
+	for _, target := range dv.data {
+		vals := []interface{}{}
+		for _, col := range cols {
+			vals = append(vals, target.(Extendable).Extra(through_prefix+col))
+		}
+		key := nexusKey(vals...)
+		tv.data[key] = append(tv.data[key], target)
+	}
+
+	return tv, nil
+}
+
+// throughModel new the target model whose repo joins the through table and
+// is conditioned to find the targets of models. the through cols referring
+// models are selected as extras through__col
+func (repo *Repo) throughModel(w with, models []interface{}) (nm interface{}, err error) {
+	nm = New(w.m)
+	r := nm.(Model).Repo()
+	r.extras = append(r.extras, through_prefix)
+	target := nm.(Model).TableName()
+	tm := newOf(w.through.m)
+	table := tm.(Model).TableName()
+	fields := []interface{}{}
+	nm.(Mapable).Mapper().each(func(fd *fieldDescriptor) bool {
+		fields = append(fields, E{target + "." + fd.colname + " AS " + fd.colname})
+		return true
+	})
+	joined := false
+	for tc, c := range w.through.second {
+		switch v := c.(type) {
+		case NWhere:
+			r.WhereRaw(target+"."+tc+" "+v.Op+" ?", v.Value)
+		case string:
+			if !joined {
+				r.Join(table, target+"."+tc, "=", table+"."+v)
+				joined = true
+				continue
+			}
+			r.WhereRaw(target + "." + tc + " = " + table + "." + v)
+		}
+	}
+	for fc, c := range w.through.first {
+		switch v := c.(type) {
+		case NWhere:
+			r.WhereRaw(table+"."+fc+" "+v.Op+" ?", v.Value)
+		case string:
+			vals := []interface{}{}
+			for _, m := range models {
+				var val interface{}
+				if val, err = repo.model.(Mapable).Mapper().colValue(m, v); err != nil {
+					return
+				}
+				vals = append(vals, val)
+			}
+			marks := strings.TrimSuffix(strings.Repeat("?, ", len(vals)), ", ")
+			r.WhereRaw(table+"."+fc+" IN ("+marks+")", vals...)
+			fields = append(fields, E{table + "." + fc + " AS " + through_prefix + fc})
+		}
+	}
+	if deleted := tm.(Mapable).Mapper().deleted; deleted != "" {
+		r.WhereRaw(table + "." + deleted + " IS NULL")
+	}
+	r.Select(fields...)
+	w.constrain(r)
+
+	return
+}
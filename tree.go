@@ -0,0 +1,143 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DeclareTree declare model a tree node whose parent is referred by col
+// parentCol, nexus "parent" and "children" are declared too, and children
+// refer back to parent. eager load levels of children like
+// With("children.children")
+//
+//	func (c *Category) Prepare() {
+//		c.DeclareTree("parent_id")
+//	}
+func (base *Base) DeclareTree(parentCol string) {
+	target := reflect.New(reflect.TypeOf(base.mapper.model).Elem()).Interface()
+	base.tree = parentCol
+	base.DeclareOne("parent", target, Nexus{base.mapper.pk: parentCol})
+	base.DeclareMany("children", target, Nexus{parentCol: base.mapper.pk})
+	base.DeclareInverse("children", "parent")
+}
+
+// Parent find the parent of the tree node
+func (base *Base) Parent() (interface{}, error) {
+	return base.One("parent")
+}
+
+// Children find the children of the tree node
+func (base *Base) Children() (Collection, error) {
+	children, err := base.Many("children")
+	if err != nil {
+		return nil, err
+	}
+	return children.(Collection), nil
+}
+
+// Ancestors find the ancestors of the tree node in one recursive query, the
+// parent first. depth limits the levels, 0 means no limit. parents are set
+// to model and each ancestor, and the level of an ancestor is the extra
+// tree__depth
+func (base *Base) Ancestors(depth int) (Collection, error) {
+	if base.tree == "" {
+		return nil, &Error{ERR_NEXUS_UNDEFINED, errors.New("model is not a tree")}
+	}
+	parent, err := base.fieldValue(base.tree)
+	if err != nil || parent == nil || reflect.ValueOf(parent).IsZero() {
+		// the zero value of the parent col means no parent
+		return Collection{}, err
+	}
+	pk := base.mapper.pk
+	nodes, err := base.treeNodes("t."+pk+" = ?", parent, "t."+pk+" = tree."+base.tree, depth)
+	if err != nil {
+		return nil, err
+	}
+	child := base.mapper.model
+	for _, node := range nodes {
+		child.(NexusOne).SetOne("parent", node)
+		child = node
+	}
+
+	return nodes, nil
+}
+
+// Descendants find the descendants of the tree node in one recursive query,
+// level by level. depth limits the levels, 0 means no limit. children are
+// set to model and each descendant above depth, and the level of a
+// descendant is the extra tree__depth
+func (base *Base) Descendants(depth int) (Collection, error) {
+	if base.tree == "" {
+		return nil, &Error{ERR_NEXUS_UNDEFINED, errors.New("model is not a tree")}
+	}
+	pk := base.mapper.pk
+	id, err := base.fieldValue(pk)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := base.treeNodes("t."+base.tree+" = ?", id, "t."+base.tree+" = tree."+pk, depth)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[string]Collection)
+	for _, node := range nodes {
+		key := nexusKey(node.(Model).Get(base.tree))
+		children[key] = append(children[key], node)
+	}
+	for _, node := range append(Collection{base.mapper.model}, nodes...) {
+		if depth > 0 && treeDepth(node) >= depth {
+			continue
+		}
+		kids := append(Collection{}, children[nexusKey(node.(Model).Get(pk))]...)
+		node.(NexusMany).SetMany("children", kids)
+	}
+
+	return nodes, nil
+}
+
+// treeNodes find the tree nodes from the nodes matching start, recursively
+// joined to the found nodes by join, with the level selected as tree__depth.
+// the visited pks, model included, are selected as tree__path like ,1,2, and
+// a visited node is never joined again, so a cycle ends
+func (base *Base) treeNodes(start string, value interface{}, join string, depth int) (Collection, error) {
+	table := base.mapper.model.(Model).TableName()
+	pk := base.mapper.pk
+	id, err := base.fieldValue(pk)
+	if err != nil {
+		return nil, err
+	}
+	r := base.Repo().Another()
+	r.extras = append(r.extras, tree_prefix)
+	dialect := dialectOf(r.modifier)
+	visited := text(dialect, "t."+pk)
+	first := []string{start, "t." + pk + " <> ?"}
+	next := []string{"tree.tree__path NOT LIKE " + concat(dialect, "'%,'", visited, "',%'")}
+	params := []interface{}{"," + normalKey(id) + ",", value, id}
+	if deleted := base.mapper.deleted; deleted != "" {
+		first = append(first, "t."+deleted+" IS NULL")
+		next = append(next, "t."+deleted+" IS NULL")
+	}
+	if depth > 0 {
+		next = append(next, "tree.tree__depth < ?")
+		params = append(params, depth)
+	}
+	sqlang := "WITH RECURSIVE tree AS (" +
+		"SELECT t.*, 1 AS tree__depth, " + text(dialect, concat(dialect, "?", visited, "','")) + " AS tree__path" +
+		" FROM " + table + " t WHERE " + strings.Join(first, " AND ") +
+		" UNION ALL " +
+		"SELECT t.*, tree.tree__depth + 1, " + concat(dialect, "tree.tree__path", visited, "','") +
+		" FROM " + table + " t JOIN tree ON " + join +
+		" WHERE " + strings.Join(next, " AND ") +
+		") SELECT * FROM tree ORDER BY tree__depth"
+	nodes, err := r.Raw(bind(dialect, sqlang, 0), params...)
+
+	return Collection(nodes), err
+}
+
+// treeDepth find the level of a node found by Ancestors or Descendants, 0 for others
+func treeDepth(node interface{}) int {
+	depth, _ := strconv.Atoi(normalKey(node.(Extendable).Extra(tree_prefix + "depth")))
+	return depth
+}
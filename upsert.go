@@ -0,0 +1,105 @@
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Upsert create models, update the update cols of the rows conflicted on the
+// conflict cols. all cols except pk and conflict cols are updated if update is empty.
+// the create and save hooks run before, the save hooks run after
+func (repo *Repo) Upsert(models []interface{}, conflict []string, update []string) error {
+	return repo.upsert(models, conflict, update, []string{}, false)
+}
+
+// UpsertExcept create models, update all cols except pk, the conflict cols and
+// the except cols, like created_at, of the rows conflicted on the conflict cols
+func (repo *Repo) UpsertExcept(models []interface{}, conflict []string, except []string) error {
+	return repo.upsert(models, conflict, []string{}, except, false)
+}
+
+// CreateOrIgnore create models, do nothing with the rows conflicted on the conflict cols
+func (repo *Repo) CreateOrIgnore(models []interface{}, conflict []string) error {
+	return repo.upsert(models, conflict, []string{}, []string{}, true)
+}
+
+func (repo *Repo) upsert(models []interface{}, conflict, update, except []string, nothing bool) error {
+	if len(models) == 0 {
+		return nil
+	}
+	mapper := repo.model.(Mapable).Mapper()
+	for _, cols := range [][]string{conflict, update, except} {
+		for _, col := range cols {
+			if !mapper.has(col) {
+				return &Error{ERR_COL_UNDEFINED, errors.New("col " + col + " undefined")}
+			}
+		}
+	}
+	r := repo.Another()
+	var data []map[string]interface{}
+	for _, m := range models {
+		if err := repo.before(m, before_create...); err != nil {
+			return err
+		}
+		data = append(data, mapper.extract(m))
+	}
+	if !nothing && len(update) == 0 {
+		skip := map[string]bool{mapper.pk: true}
+		for _, col := range append(append([]string{}, conflict...), except...) {
+			skip[col] = true
+		}
+		for col, _ := range data[0] {
+			if !skip[col] {
+				update = append(update, col)
+			}
+		}
+		sort.Strings(update)
+	}
+	dialect := dialectOf(repo.modifier)
+	if dialect != dialect_mysql && len(conflict) == 0 && !nothing && len(update) != 0 {
+		return errors.New("conflict cols required to update on conflict")
+	}
+	if dialect == dialect_mysql && len(update) == 0 && mapper.pk == "" {
+		return errors.New("pk required to ignore on duplicate key")
+	}
+	sqlang := r.ForInsert(data) + upsertClause(dialect, conflict, update, mapper.pk)
+	// a row may be created or updated, so only the save hooks run after
+	return repo.write(models, nil, []Event{AFTER_SAVE}, func() error {
+		db := repo.model.(Model).DB()
+		if _, err := db.Exec(sqlang, r.Params()...); err != nil {
+			return err
+		}
+		for _, m := range models {
+			m.(Model).SetFresh(false)
+		}
+		return nil
+	})
+}
+
+// upsertClause generate the on conflict clause of insert, an empty update means do nothing
+func upsertClause(dialect int, conflict, update []string, pk string) string {
+	sets := []string{}
+	if dialect == dialect_mysql {
+		if len(update) == 0 {
+			// mysql can't do nothing on duplicate key, a noop update instead
+			return " ON DUPLICATE KEY UPDATE " + pk + " = " + pk
+		}
+		for _, col := range update {
+			sets = append(sets, col+" = VALUES("+col+")")
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	clause := " ON CONFLICT"
+	if len(conflict) != 0 {
+		clause += " (" + strings.Join(conflict, ", ") + ")"
+	}
+	if len(update) == 0 {
+		return clause + " DO NOTHING"
+	}
+	for _, col := range update {
+		sets = append(sets, col+" = EXCLUDED."+col)
+	}
+
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
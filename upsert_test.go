@@ -0,0 +1,25 @@
+package model
+
+import (
+	. "testing"
+)
+
+func TestUpsertClause(t *T) {
+	cases := []struct {
+		dialect  int
+		conflict []string
+		update   []string
+		expect   string
+	}{
+		{dialect_mysql, []string{"id"}, []string{"name", "age"}, " ON DUPLICATE KEY UPDATE name = VALUES(name), age = VALUES(age)"},
+		{dialect_mysql, []string{"id"}, []string{}, " ON DUPLICATE KEY UPDATE id = id"},
+		{dialect_pgsql, []string{"id"}, []string{"name"}, " ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name"},
+		{dialect_pgsql, []string{}, []string{}, " ON CONFLICT DO NOTHING"},
+		{dialect_sqlite, []string{"name", "age"}, []string{}, " ON CONFLICT (name, age) DO NOTHING"},
+	}
+	for _, c := range cases {
+		if clause := upsertClause(c.dialect, c.conflict, c.update, "id"); clause != c.expect {
+			t.Fatalf("upsert clause error: %v", clause)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+	. "github.com/yang-zzhong/go-querybuilder"
+	"strings"
+)
+
+// WhereHas filter the models having at least one model of nexus name matching
+// the conditions added by handle, handle can be nil. the nexus table is aliased
+// as name in the sub query, so a nexus to the same table works
+//
+//	repo.WhereHas("books", func(r *Repo) {
+//		r.Where("published", true)
+//	})
+func (repo *Repo) WhereHas(name string, handle func(r *Repo)) *Repo {
+	return repo.whereExists(name, handle, "EXISTS")
+}
+
+// WhereDoesntHave filter the models having no model of nexus name matching
+// the conditions added by handle, handle can be nil
+func (repo *Repo) WhereDoesntHave(name string, handle func(r *Repo)) *Repo {
+	return repo.whereExists(name, handle, "NOT EXISTS")
+}
+
+func (repo *Repo) whereExists(name string, handle func(r *Repo), exists string) *Repo {
+	if repo.err != nil {
+		return repo
+	}
+	sqlang, params, err := repo.existsOf(name, handle)
+	if err != nil {
+		repo.err = err
+		return repo
+	}
+	repo.WhereRaw(exists+" ("+unbind(dialectOf(repo.modifier), sqlang)+")", params...)
+
+	return repo
+}
+
+// existsOf build the sub query finding nexus name of the repo model, with the
+// target table aliased as name
+func (repo *Repo) existsOf(name string, handle func(r *Repo)) (string, []interface{}, error) {
+	rel := repo.relation(name)
+	if rel.t != t_one && rel.t != t_many && rel.t != t_pivot {
+		return "", nil, &Error{ERR_NEXUS_UNDEFINED, errors.New("nexus " + name + " not exists")}
+	}
+	r := newOf(rel.target).(Model).Repo().aliased(name)
+	table := repo.table()
+	target := name
+	r.Select(E{"1"})
+	if rel.t == t_pivot {
+		p := rel.pivot
+		conds := []string{}
+		params := []interface{}{}
+		for _, pair := range []struct {
+			table string
+			n     Nexus
+		}{{table, p.local}, {target, p.foreign}} {
+			for pc, c := range pair.n {
+				switch v := c.(type) {
+				case NWhere:
+					conds = append(conds, p.table+"."+pc+" "+v.Op+" ?")
+					params = append(params, v.Value)
+				case string:
+					conds = append(conds, p.table+"."+pc+" = "+pair.table+"."+v)
+				}
+			}
+		}
+		r.WhereRaw("EXISTS (SELECT 1 FROM "+p.table+" WHERE "+strings.Join(conds, " AND ")+")", params...)
+	}
+	for af, bf := range rel.n {
+		switch v := bf.(type) {
+		case NWhere:
+			r.Where(target+"."+af, v.Op, v.Value)
+		case string:
+			r.WhereRaw(target + "." + af + " = " + table + "." + v)
+		}
+	}
+	if handle != nil {
+		handle(r)
+	}
+	if err := r.prepare(); err != nil {
+		return "", nil, err
+	}
+
+	return r.ForQuery(), r.Params(), nil
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	. "github.com/yang-zzhong/go-querybuilder"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// an aggregate of nexus
+type aggregate struct {
+	name string // nexus name
+	fn   string // aggregate function
+	col  string // aggregated col of nexus target
+	as   string // extra name of the result
+}
+
+// WithCount tell repo to count nexus name of each fetched model, the result is
+// set as extra name_count of the model
+//
+//	users := repo.WithCount("books").MustFetch()
+//	count := users[0].(*User).Extra("books_count").(int)
+func (repo *Repo) WithCount(name string) *Repo {
+	return repo.withAggregate(name, "COUNT", "")
+}
+
+// WithSum tell repo to sum col of nexus name of each fetched model, the result
+// is set as extra name_sum_col of the model
+func (repo *Repo) WithSum(name, col string) *Repo {
+	return repo.withAggregate(name, "SUM", col)
+}
+
+// WithAvg tell repo to average col of nexus name, set as extra name_avg_col
+func (repo *Repo) WithAvg(name, col string) *Repo {
+	return repo.withAggregate(name, "AVG", col)
+}
+
+// WithMin tell repo to find min col of nexus name, set as extra name_min_col
+// of the field type of col, so it works on cols like datetime and varchar too
+func (repo *Repo) WithMin(name, col string) *Repo {
+	return repo.withAggregate(name, "MIN", col)
+}
+
+// WithMax tell repo to find max col of nexus name, set as extra name_max_col
+func (repo *Repo) WithMax(name, col string) *Repo {
+	return repo.withAggregate(name, "MAX", col)
+}
+
+func (repo *Repo) withAggregate(name, fn, col string) *Repo {
+	as := name + "_" + strings.ToLower(fn)
+	if col != "" {
+		as += "_" + col
+	}
+	repo.aggs = append(repo.aggs, aggregate{name, fn, col, as})
+	return repo
+}
+
+// bindAggregates run one grouped query for each aggregate and set the
+// result to each model as extra, COUNT as int, SUM and AVG as float64, MIN
+// and MAX as the field type of the col. the result of a model without
+// targets is 0 for COUNT, nil for others
+func (repo *Repo) bindAggregates(models []interface{}) error {
+	if len(models) == 0 {
+		return nil
+	}
+	for _, agg := range repo.aggs {
+		values, keys, err := repo.aggregate(agg, models)
+		if err != nil {
+			return err
+		}
+		_, n, _ := repo.nexus(agg.name)
+		for _, m := range models {
+			vals := []interface{}{}
+			for _, key := range keys {
+				val, _ := repo.model.(Mapable).Mapper().colValue(m, n[key].(string))
+				vals = append(vals, val)
+			}
+			value := values[nexusKey(vals...)]
+			if agg.fn == "COUNT" && value == nil {
+				value = 0
+			}
+			m.(Extendable).SetExtra(agg.as, value)
+		}
+	}
+
+	return nil
+}
+
+// aggregate query the aggregate of models, the results are keyed by the
+// nexus key of the target cols in keys
+func (repo *Repo) aggregate(agg aggregate, models []interface{}) (values map[string]interface{}, keys []string, err error) {
+	var r *Repo
+	if r, keys, err = repo.aggregateRepo(agg, models); err != nil {
+		return
+	}
+	target := newOf(r.model)
+	values = make(map[string]interface{})
+	err = r.Query(func(rows *sql.Rows, _ []string) error {
+		var value interface{}
+		vals := make([]interface{}, len(keys))
+		dest := []interface{}{&value}
+		for i, _ := range vals {
+			dest = append(dest, &vals[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return &Error{ERR_SCAN, err}
+		}
+		if value, err = aggregateValue(agg.fn, value); err != nil {
+			return err
+		}
+		if value != nil && (agg.fn == "MIN" || agg.fn == "MAX") {
+			if value, err = typed(target, agg.col, value); err != nil {
+				return err
+			}
+		}
+		values[nexusKey(vals...)] = value
+		return nil
+	})
+
+	return
+}
+
+// aggregateRepo new the target repo of the aggregate query of models, grouped
+// by the target cols in keys
+func (repo *Repo) aggregateRepo(agg aggregate, models []interface{}) (r *Repo, keys []string, err error) {
+	m, n, t := repo.nexus(agg.name)
+	if t != t_one && t != t_many {
+		err = &Error{ERR_NEXUS_UNDEFINED, errors.New("has one or has many nexus " + agg.name + " not exists")}
+		return
+	}
+	r = newOf(m).(Model).Repo()
+	expr := "COUNT(1)"
+	if agg.col != "" {
+		if !r.model.(Mapable).Mapper().has(agg.col) {
+			err = &Error{ERR_COL_UNDEFINED, errors.New("col " + agg.col + " undefined")}
+			return
+		}
+		expr = agg.fn + "(" + agg.col + ")"
+	}
+	for af, bf := range n {
+		switch v := bf.(type) {
+		case NWhere:
+			r.Where(af, v.Op, v.Value)
+		case string:
+			keys = append(keys, af)
+			vals := []interface{}{}
+			for _, model := range models {
+				var val interface{}
+				if val, err = repo.model.(Mapable).Mapper().colValue(model, v); err != nil {
+					return
+				}
+				vals = append(vals, val)
+			}
+			r.WhereIn(af, vals)
+		}
+	}
+	sort.Strings(keys)
+	fields := []interface{}{E{expr + " AS aggregate"}}
+	for _, key := range keys {
+		fields = append(fields, key)
+	}
+	r.Select(fields...)
+	if len(keys) != 0 {
+		r.GroupBy(keys...)
+	}
+
+	return
+}
+
+// typed convert value to the field type of col of model m
+func typed(m interface{}, col string, value interface{}) (interface{}, error) {
+	if err := m.(pushable).assign(col, value); err != nil {
+		return nil, &Error{ERR_SCAN, err}
+	}
+	mapper := m.(Mapable).Mapper()
+	fd, _ := mapper.fd(col)
+	return mapper.value.FieldByName(fd.fieldname).Interface(), nil
+}
+
+// aggregateValue convert the scanned result of aggregate function fn, NULL
+// kept as nil
+func aggregateValue(fn string, value interface{}) (interface{}, error) {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	switch fn {
+	case "COUNT":
+		count, err := strconv.Atoi(normalKey(value))
+		if err != nil {
+			return nil, &Error{ERR_SCAN, err}
+		}
+		return count, nil
+	case "SUM", "AVG":
+		f, err := strconv.ParseFloat(normalKey(value), 64)
+		if err != nil {
+			return nil, &Error{ERR_SCAN, err}
+		}
+		return f, nil
+	}
+	return value, nil
+}
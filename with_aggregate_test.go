@@ -0,0 +1,41 @@
+package model
+
+import (
+	. "testing"
+	"time"
+)
+
+func TestAggregateValue(t *T) {
+	at := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		fn     string
+		value  interface{}
+		expect interface{}
+	}{
+		{"COUNT", int64(3), 3},
+		{"SUM", []byte("12.5"), 12.5},
+		{"AVG", int64(2), 2.0},
+		{"MAX", []byte("yang"), "yang"},
+		{"MIN", at, at},
+		{"MAX", nil, nil},
+	}
+	for _, c := range cases {
+		if value, err := aggregateValue(c.fn, c.value); err != nil || value != c.expect {
+			t.Fatalf("aggregate value of %v %v error: %v", c.fn, c.value, value)
+		}
+	}
+}
+
+func TestTyped(t *T) {
+	m := New(new(FilterUser))
+	if value, err := typed(m, "age", []byte("18")); err != nil || value != 18 {
+		t.Fatalf("typed int error: %v", value)
+	}
+	at := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	if value, err := typed(m, "created_at", []byte("2018-01-02 03:04:05")); err != nil || !value.(time.Time).Equal(at) {
+		t.Fatalf("typed time error: %v", value)
+	}
+	if _, err := typed(m, "age", "eighteen"); err == nil {
+		t.Fatal("typed bad int error")
+	}
+}